@@ -2,50 +2,187 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"github.com/dfryer1193/golinks/config"
 	"github.com/dfryer1193/golinks/internal/handler"
-	"github.com/dfryer1193/mjolnir/router"
+	"github.com/dfryer1193/golinks/internal/server"
+	"github.com/dfryer1193/golinks/internal/tracing"
+	"github.com/go-chi/chi/v5"
+	"net"
 	"net/http"
 	"os"
-	"os/signal"
 	"time"
 
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
 
+// shutdownTimeout is how long servers get to finish their requests, and links
+// to be saved, once the process is asked to stop.
+const shutdownTimeout = 5 * time.Second
+
 func main() {
 	cfg := config.GetConfig()
-	zerolog.SetGlobalLevel(cfg.LogLevel)
+	configureLogging(cfg)
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to set up tracing")
+	}
+
+	routerOptions := server.RouterOptions{
+		TrustedProxies:     cfg.TrustedProxies,
+		TrustUnixSocket:    cfg.TrustUnixSocket,
+		RedirectSampleRate: cfg.RedirectLogSample,
+	}
+	r := server.NewRouter(routerOptions)
+	var adminRouter *chi.Mux
+	if cfg.AdminAddress != "" {
+		adminRouter = server.NewRouter(routerOptions)
+	}
+	if cfg.TLSCert != "" && cfg.HSTSMaxAge > 0 {
+		r.Use(server.HSTS(cfg.HSTSMaxAge))
+		if adminRouter != nil {
+			adminRouter.Use(server.HSTS(cfg.HSTSMaxAge))
+		}
+	}
+	service := handler.NewGoLinkService(r, adminRouter, cfg)
+	lifecycle := server.NewLifecycle()
 
-	r := router.New()
-	handler.NewGoLinkService(r, cfg)
+	srv := &http.Server{Handler: r, ConnContext: server.ConnContext}
+	servers := []*http.Server{srv}
 
-	srv := &http.Server{
-		Addr:    fmt.Sprintf(":%d", cfg.Port),
-		Handler: r,
+	useTLS := cfg.TLSCert != ""
+	var certReloader *server.CertReloader
+	if useTLS {
+		certReloader, err = server.NewCertReloader(cfg.TLSCert, cfg.TLSKey)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to load TLS certificate")
+		}
+		srv.TLSConfig = &tls.Config{
+			MinVersion:     cfg.TLSMinVersion,
+			GetCertificate: certReloader.GetCertificate,
+		}
 	}
 
-	go func() {
-		log.Info().Msg("Starting server on port :" + fmt.Sprint(cfg.Port))
-		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+	listeners, err := server.SystemdListeners()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to use sockets passed by systemd")
+	}
+	if cfg.Port != 0 {
+		listener, err := net.Listen("tcp", fmt.Sprintf(":%d", cfg.Port))
+		if err != nil {
 			log.Fatal().Err(err).Msg("Failed to start server")
 		}
-	}()
+		listeners = append(listeners, listener)
+	}
+	if len(listeners) == 0 && cfg.UnixSocket == "" {
+		log.Fatal().Msg("Nothing to listen on: set -port or -unix-socket, or use systemd socket activation")
+	}
+	for _, listener := range listeners {
+		go serve(srv, listener, useTLS)
+	}
+
+	// The Unix socket is only reachable from this host, typically by a reverse
+	// proxy that terminates TLS itself.
+	if cfg.UnixSocket != "" {
+		listener, err := server.UnixListener(cfg.UnixSocket, cfg.UnixSocketMode)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to listen on Unix socket")
+		}
+		go serve(srv, listener, false)
+	}
 
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, os.Interrupt)
-	<-quit
+	if adminRouter != nil {
+		adminSrv := &http.Server{Handler: adminRouter, TLSConfig: srv.TLSConfig}
+		servers = append(servers, adminSrv)
 
-	log.Info().Msg("Shutting down server...")
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		listener, err := net.Listen("tcp", cfg.AdminAddress)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to start admin server")
+		}
+		go serve(adminSrv, listener, useTLS)
+	}
+
+	if useTLS && cfg.HTTPRedirectPort != 0 {
+		httpsPort := cfg.Port
+		if httpsPort == 0 {
+			httpsPort = 443
+		}
+		redirectSrv := &http.Server{
+			Addr:    fmt.Sprintf(":%d", cfg.HTTPRedirectPort),
+			Handler: server.RedirectToHTTPS(httpsPort),
+		}
+		servers = append(servers, redirectSrv)
+
+		go func() {
+			log.Info().Msg("Redirecting HTTP to HTTPS on port :" + fmt.Sprint(cfg.HTTPRedirectPort))
+			if err := redirectSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Fatal().Err(err).Msg("Failed to start HTTP redirect server")
+			}
+		}()
+	}
+
+	// Servers finish the requests they have before links stop accepting
+	// changes, so that no change is refused or lost while shutting down.
+	for _, srv := range servers {
+		lifecycle.OnStop("server", srv.Shutdown)
+	}
+	lifecycle.OnStop("links", service.Close)
+	if certReloader != nil {
+		lifecycle.OnStop("certificates", func(context.Context) error { return certReloader.Close() })
+	}
+	lifecycle.OnStop("tracing", shutdownTracing)
+
+	sig := lifecycle.Wait()
+	log.Info().Str("signal", sig.String()).Msg("Shutting down server...")
+	service.Drain()
+	if cfg.ShutdownDelay > 0 {
+		log.Info().Dur("delay", cfg.ShutdownDelay).Msg("Failing readiness before closing the listener")
+		time.Sleep(cfg.ShutdownDelay)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
 	defer cancel()
 
-	if err := srv.Shutdown(ctx); err != nil {
-		log.Fatal().Err(err).Msg("Failed to shutdown server")
+	if err := lifecycle.Stop(ctx); err != nil {
+		log.Fatal().Err(err).Msg("Failed to shut down cleanly")
 	}
 
 	log.Info().Msg("Server stopped")
 }
+
+// configureLogging sets up the global logger, which handlers also fall back to
+// when a request carries no logger of its own.
+func configureLogging(cfg *config.Config) {
+	zerolog.SetGlobalLevel(cfg.LogLevel)
+	if cfg.LogFormat == "json" {
+		log.Logger = zerolog.New(os.Stdout).With().Timestamp().Logger()
+	} else {
+		log.Logger = log.Output(zerolog.ConsoleWriter{
+			Out:        os.Stdout,
+			TimeFormat: time.RFC3339Nano,
+		})
+	}
+	zerolog.DefaultContextLogger = &log.Logger
+}
+
+// serve serves srv on listener until the server is shut down.
+func serve(srv *http.Server, listener net.Listener, useTLS bool) {
+	log.Info().
+		Str("network", listener.Addr().Network()).
+		Str("address", listener.Addr().String()).
+		Bool("tls", useTLS).
+		Msg("Starting server")
+
+	var err error
+	if useTLS {
+		err = srv.ServeTLS(listener, "", "")
+	} else {
+		err = srv.Serve(listener)
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal().Err(err).Msg("Failed to serve")
+	}
+}
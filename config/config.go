@@ -1,22 +1,63 @@
 package config
 
 import (
+	"errors"
 	"flag"
 	"fmt"
+	"github.com/dfryer1193/golinks/internal/links"
 	"github.com/dfryer1193/golinks/internal/links/storage"
+	"github.com/dfryer1193/golinks/internal/normalize"
+	"github.com/dfryer1193/golinks/internal/search"
+	"github.com/dfryer1193/golinks/internal/server"
+	"github.com/dfryer1193/golinks/internal/tracing"
 	"github.com/rs/zerolog"
+	"io"
+	"io/fs"
+	"net"
+	"net/netip"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type Config struct {
-	Port        int
-	StorageType storage.StorageType
-	ConfigFile  string
-	LogLevel    zerolog.Level
+	Port               int
+	StorageType        storage.StorageType
+	ConfigFile         string
+	LogLevel           zerolog.Level
+	ArchiveGracePeriod time.Duration
+	TrashRetention     time.Duration
+	Normalization      []normalize.Rule
+	PathChars          string
+	MaxPathLength      int
+	ReservedPaths      []string
+	TargetPolicy       links.TargetPolicy
+	InternalDomains    []string
+	SearchMaxDistance  int
+	SearchLimit        int
+	PopularityHalfLife time.Duration
+	ShutdownDelay      time.Duration
+	TLSCert            string
+	TLSKey             string
+	TLSMinVersion      uint16
+	HTTPRedirectPort   int
+	HSTSMaxAge         time.Duration
+	UnixSocket         string
+	UnixSocketMode     fs.FileMode
+	AdminAddress       string
+	LogFormat          string
+	RedirectLogSample  int
+	TrustedProxies     []netip.Prefix
+	TrustUnixSocket    bool
+	Tracing            string
+	RedirectRateLimit  server.Limit
+	SearchRateLimit    server.Limit
+	MutationRateLimit  server.Limit
 }
 
-func help() {
+// printHelp writes the usage of golinks to w.
+func printHelp(w io.Writer) {
 	helptext :=
 		`golinks: a simple self-hosted implementation of go links for use in a self-
 hosted environment.
@@ -24,7 +65,19 @@ hosted environment.
 Usage: golinks [-port 8080] [-config ./links]
 
 -h                                      Show this help message
--port <number>                          The port to listen on (default: 8080)
+-port <number>                          The port to listen on (default: 8080).
+                                        Use 0 to only listen on -unix-socket or
+                                        on sockets passed by systemd
+-admin-address <host:port>              Serve the API routes that change or
+                                        export links, and /healthz and /readyz,
+                                        only on this address, such as
+                                        "127.0.0.1:8081". The admin address
+                                        serves every other route too. Defaults
+                                        to serving everything on -port
+-unix-socket <path>                     Also listen on a Unix domain socket at
+                                        this path, without TLS
+-unix-socket-mode <mode>                The octal file mode of -unix-socket.
+                                        Defaults to "0660"
 -storage <FILE|NONE>                    The type of storage to use for
                                         persistence. Defaults to "FILE". Storage
                                         types:
@@ -41,6 +94,118 @@ Usage: golinks [-port 8080] [-config ./links]
                                             * "/etc/golinks/links"
 -level <loglevel>                       The loglevel to log at. Defaults to
                                         "INFO"
+-log-format <console|json>              How logs are written to stdout.
+                                        Defaults to "console"
+-redirect-log-sample <number>           Log one in this many successful
+                                        redirects. Other requests are always
+                                        logged. Defaults to 1
+-trusted-proxies <addresses>            Comma separated list of IP addresses
+                                        and CIDR ranges of reverse proxies,
+                                        whose X-Forwarded-For and X-Real-IP
+                                        headers are used for the client address
+                                        in logs and rate limits. "unix" trusts
+                                        every peer on -unix-socket. Defaults to
+                                        trusting none
+-rate-limit-redirects <requests/period> How many links each client address and
+                                        bearer token can follow, such as "20/s"
+                                        or "600/m". Use 0 for no limit.
+                                        Defaults to 0
+-rate-limit-search <requests/period>    How many searches, suggestions and
+                                        listings each client can request.
+                                        Defaults to 0
+-rate-limit-mutations <requests/period> How many changes, restores and imports
+                                        each client can make. Defaults to
+                                        "60/m"
+-tracing <none|stdout|otlp>             Where OpenTelemetry traces are sent.
+                                        "otlp" is configured with the standard
+                                        OTEL_EXPORTER_OTLP_* environment
+                                        variables. Defaults to "none"
+-archive-grace <duration>               How long an expired link is kept before
+                                        it is moved to the archive file next to
+                                        the config, along with its aliases.
+                                        Defaults to "168h"
+-trash-retention <duration>             How long a deleted link can be restored
+                                        from the trash before it is purged.
+                                        Defaults to "720h"
+-normalize <rules>                      Comma separated list of rules applied to
+                                        paths before they are matched, so that
+                                        e.g. go/OnCall and go/on-call resolve to
+                                        the same link. Rules:
+                                            * case: ignore case
+                                            * separators: ignore '-', '_', '.'
+                                            * nfc: compose unicode characters
+                                        Use "none" to match paths exactly.
+                                        Defaults to "case,separators,nfc"
+-path-chars <class>                     The characters allowed in a path, as the
+                                        body of a regular expression character
+                                        class. Defaults to "\p{L}\p{N}_.~-"
+-max-path-length <number>               The longest path allowed, in characters.
+                                        Defaults to 64
+-reserved-paths <paths>                 Comma separated list of additional paths
+                                        that cannot be used for links. The
+                                        paths used by golinks itself are always
+                                        reserved
+-allowed-schemes <schemes>              Comma separated list of URL schemes that
+                                        targets may use. Defaults to
+                                        "http,https"
+-allow-relative-targets                 Allow targets that are not absolute URLs
+-allowed-domains <domains>              Comma separated list of domains targets
+                                        must belong to. Subdomains are included.
+                                        Defaults to allowing any domain
+-denied-domains <domains>               Comma separated list of domains targets
+                                        cannot belong to. Subdomains are
+                                        included
+-block-private-targets                  Reject targets that are or resolve to
+                                        loopback, private or link-local
+                                        addresses
+-internal-domains <domains>             Comma separated list of trusted domains.
+                                        When set, links to any other domain
+                                        show an interstitial page before
+                                        redirecting unless the link sets its
+                                        own redirect mode
+-search-max-distance <number>           The largest number of typos a search
+                                        term may contain and still match a
+                                        path. Use -1 to disable fuzzy matching.
+                                        Defaults to 2
+-search-limit <number>                  The number of search results returned
+                                        when the request does not set a limit.
+                                        Defaults to 20
+-popularity-half-life <duration>        How long it takes for the clicks a link
+                                        got to count half as much when ordering
+                                        equally good search results. Use 0 to
+                                        never forget clicks. Defaults to "720h"
+-tls-cert <path>                        A PEM certificate to serve HTTPS with on
+                                        -port. The certificate is reloaded when
+                                        the file changes. Requires -tls-key
+-tls-key <path>                         The PEM private key of -tls-cert
+-tls-min-version <version>              The oldest TLS version accepted, one of
+                                        1.0, 1.1, 1.2 or 1.3. Defaults to "1.2"
+-http-redirect-port <number>            When serving HTTPS, also listen for
+                                        plain HTTP on this port and redirect it
+                                        to HTTPS, so that http://go/ links keep
+                                        working. Disabled by default
+-hsts-max-age <duration>                When serving HTTPS, how long browsers
+                                        should only use HTTPS for the server,
+                                        sent as a Strict-Transport-Security
+                                        header. Disabled by default
+-shutdown-delay <duration>              How long /readyz fails before the server
+                                        stops accepting connections on shutdown,
+                                        so that load balancers can stop sending
+                                        requests first. Defaults to "0s"
+-server-config <path>                   A YAML file to read settings from
+
+When started by systemd socket activation, golinks also serves the sockets it
+is passed, with TLS if it is enabled.
+
+Settings can also be given as environment variables and in the server config
+file. Each is named after its flag: -search-limit is read from
+GOLINKS_SEARCH_LIMIT and from the search-limit key. Flags take precedence over
+environment variables, which take precedence over the server config file:
+
+    port: 80
+    config: /var/lib/golinks/links
+    internal-domains:
+      - example.com
 
 Config format:
 The config file is a simple plaintext file consisting of one key/value pair per
@@ -49,56 +214,295 @@ line, separated by spaces, like so:
     test https://www.google.com
 
 The value of the pair must be a full web address. Query params are not
-respected, though full paths are.`
+respected, though full paths are.
+
+A line may be followed by optional attributes in the form name=value:
+
+    offsite https://example.com/offsite activeFrom=2025-06-01T00:00:00Z expiresAt=2025-06-04T00:00:00Z
+
+activeFrom and expiresAt are RFC 3339 timestamps. Outside of that window the
+link does not resolve. redirect is one of 301, 302, 307 (the default), 308 or
+interstitial, which shows the target on a page before continuing.
+description and owner are free text, with spaces written as %20. tags is a
+comma separated list. createdAt and updatedAt are maintained by golinks.
+
+Visiting a link with a trailing "+", like go/test+, shows its details instead of
+following it.
+
+An alias follows the target of another link. Its target is written as "-":
 
-	fmt.Println(helptext)
-	os.Exit(0)
+    k8s - aliasOf=kubernetes`
+
+	fmt.Fprintln(w, helptext)
 }
 
-func getLevelFromArg(arg string) zerolog.Level {
-	switch strings.ToUpper(arg) {
-	case "TRACE":
-		return zerolog.TraceLevel
-	case "DEBUG":
-		return zerolog.DebugLevel
-	case "INFO":
-		return zerolog.InfoLevel
-	case "WARN":
-		return zerolog.WarnLevel
-	case "ERROR":
-		return zerolog.ErrorLevel
-	case "FATAL":
-		return zerolog.FatalLevel
-	case "PANIC":
-		return zerolog.PanicLevel
-	default:
-		return zerolog.InfoLevel
+// GetConfig loads the configuration from the command line, the environment and
+// the server config file, and exits with a message naming the offending
+// setting if it is invalid. Asking for help exits successfully.
+func GetConfig() *Config {
+	cfg, err := Load(os.Args[1:], os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
+	return cfg
 }
 
-func GetConfig() *Config {
+// Load builds the configuration from the given arguments, environment and the
+// server config file they point to. Each setting is taken from the first of
+// these that sets it:
+//
+//  1. a command-line flag,
+//  2. the GOLINKS_ environment variable named after the flag, such as
+//     GOLINKS_SEARCH_LIMIT for -search-limit,
+//  3. the key named after the flag in the server config file,
+//  4. its default.
+//
+// If the command line cannot be parsed, the usage is written to stderr and the
+// error returned; for -h, that error is flag.ErrHelp.
+func Load(args []string, getenv func(string) string) (*Config, error) {
+	flags := flag.NewFlagSet("golinks", flag.ContinueOnError)
+	var serverConfig string
 	var port int
 	var storageTypeString string
 	var configFile string
 	var stringLogLevel string
-	flag.IntVar(&port, "port", 8080, "The port to listen on")
-	flag.StringVar(&storageTypeString, "storage", "FILE", "The type of storage to use for persistence")
-	flag.StringVar(&configFile, "config", "", "Location of the config file. Ignored if storageType is 'NONE'")
-	flag.StringVar(&stringLogLevel, "level", "INFO", "The level to log at")
-	flag.Usage = help
+	var archiveGracePeriod time.Duration
+	var trashRetention time.Duration
+	var normalization string
+	var pathChars string
+	var maxPathLength int
+	var reservedPaths string
+	var allowedSchemes string
+	var allowRelativeTargets bool
+	var allowedDomains string
+	var deniedDomains string
+	var blockPrivateTargets bool
+	var internalDomains string
+	var searchMaxDistance int
+	var searchLimit int
+	var popularityHalfLife time.Duration
+	var shutdownDelay time.Duration
+	var tlsCert string
+	var tlsKey string
+	var tlsMinVersion string
+	var httpRedirectPort int
+	var hstsMaxAge time.Duration
+	var unixSocket string
+	var adminAddress string
+	var logFormat string
+	var redirectLogSample int
+	var trustedProxies string
+	var tracingExporter string
+	var redirectRateLimit string
+	var searchRateLimit string
+	var mutationRateLimit string
+	var unixSocketMode string
+	flags.IntVar(&port, "port", 8080, "The port to listen on")
+	flags.StringVar(&storageTypeString, "storage", "FILE", "The type of storage to use for persistence")
+	flags.StringVar(&configFile, "config", "", "Location of the config file. Ignored if storageType is 'NONE'")
+	flags.StringVar(&stringLogLevel, "level", "INFO", "The level to log at")
+	flags.DurationVar(&archiveGracePeriod, "archive-grace", 7*24*time.Hour, "How long expired links are kept before archiving")
+	flags.DurationVar(&trashRetention, "trash-retention", 30*24*time.Hour, "How long deleted links can be restored")
+	flags.StringVar(&normalization, "normalize", "case,separators,nfc", "How paths are normalized before matching")
+	flags.StringVar(&pathChars, "path-chars", links.DefaultPathChars, "The characters allowed in a path")
+	flags.IntVar(&maxPathLength, "max-path-length", links.DefaultMaxPathLength, "The longest path allowed")
+	flags.StringVar(&reservedPaths, "reserved-paths", "", "Additional paths that cannot be used for links")
+	flags.StringVar(&allowedSchemes, "allowed-schemes", "http,https", "URL schemes that targets may use")
+	flags.BoolVar(&allowRelativeTargets, "allow-relative-targets", false, "Allow targets that are not absolute URLs")
+	flags.StringVar(&allowedDomains, "allowed-domains", "", "Domains that targets must belong to")
+	flags.StringVar(&deniedDomains, "denied-domains", "", "Domains that targets cannot belong to")
+	flags.BoolVar(&blockPrivateTargets, "block-private-targets", false, "Reject targets on private addresses")
+	flags.StringVar(&internalDomains, "internal-domains", "", "Trusted domains that skip the interstitial page")
+	flags.IntVar(&searchMaxDistance, "search-max-distance", search.DefaultMaxDistance, "The most typos a search term may contain")
+	flags.IntVar(&searchLimit, "search-limit", search.DefaultLimit, "The default number of search results")
+	flags.DurationVar(&popularityHalfLife, "popularity-half-life", 30*24*time.Hour, "How long until clicks count half as much in search")
+	flags.StringVar(&logFormat, "log-format", "console", "How logs are written")
+	flags.IntVar(&redirectLogSample, "redirect-log-sample", 1, "Log one in this many successful redirects")
+	flags.StringVar(&trustedProxies, "trusted-proxies", "", "Reverse proxies whose forwarding headers are trusted")
+	flags.StringVar(&tracingExporter, "tracing", tracing.ExporterNone, "Where traces are sent")
+	flags.StringVar(&redirectRateLimit, "rate-limit-redirects", "0", "How many links each client can follow per period")
+	flags.StringVar(&searchRateLimit, "rate-limit-search", "0", "How many searches each client can make per period")
+	flags.StringVar(&mutationRateLimit, "rate-limit-mutations", "60/m", "How many changes each client can make per period")
+	flags.StringVar(&adminAddress, "admin-address", "", "The address to serve administrative routes on")
+	flags.StringVar(&unixSocket, "unix-socket", "", "The path of a Unix domain socket to listen on")
+	flags.StringVar(&unixSocketMode, "unix-socket-mode", "0660", "The file mode of the Unix domain socket")
+	flags.StringVar(&tlsCert, "tls-cert", "", "The PEM certificate to serve HTTPS with")
+	flags.StringVar(&tlsKey, "tls-key", "", "The PEM private key of the certificate")
+	flags.StringVar(&tlsMinVersion, "tls-min-version", "1.2", "The oldest TLS version accepted")
+	flags.IntVar(&httpRedirectPort, "http-redirect-port", 0, "The port to redirect plain HTTP to HTTPS on")
+	flags.DurationVar(&hstsMaxAge, "hsts-max-age", 0, "How long browsers should only use HTTPS")
+	flags.DurationVar(&shutdownDelay, "shutdown-delay", 0, "How long readiness fails before shutting down")
+	flags.StringVar(&serverConfig, serverConfigKey, "", "Location of the server config file")
+	flags.Usage = func() { printHelp(flags.Output()) }
 
-	flag.Parse()
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	sources, err := applySources(flags, getenv)
+	if err != nil {
+		return nil, err
+	}
 
 	level, err := zerolog.ParseLevel(stringLogLevel)
 	if err != nil {
-		fmt.Println("Invalid log level")
-		os.Exit(1)
+		return nil, sources.invalid("level", err)
+	}
+
+	if logFormat != "console" && logFormat != "json" {
+		return nil, sources.invalid("log-format", fmt.Errorf("unknown format %q: expected console or json", logFormat))
+	}
+
+	if !tracing.IsValidExporter(tracingExporter) {
+		return nil, sources.invalid("tracing", fmt.Errorf("unknown exporter %q: expected none, stdout or otlp", tracingExporter))
+	}
+
+	redirectRate, err := server.ParseLimit(redirectRateLimit)
+	if err != nil {
+		return nil, sources.invalid("rate-limit-redirects", err)
+	}
+	searchRate, err := server.ParseLimit(searchRateLimit)
+	if err != nil {
+		return nil, sources.invalid("rate-limit-search", err)
+	}
+	mutationRate, err := server.ParseLimit(mutationRateLimit)
+	if err != nil {
+		return nil, sources.invalid("rate-limit-mutations", err)
+	}
+
+	if redirectLogSample <= 0 {
+		return nil, sources.invalid("redirect-log-sample", errors.New("must be a positive number"))
+	}
+
+	var proxyAddresses []string
+	trustUnixSocket := false
+	for _, proxy := range splitList(trustedProxies) {
+		if proxy == "unix" {
+			trustUnixSocket = true
+			continue
+		}
+		proxyAddresses = append(proxyAddresses, proxy)
+	}
+	proxies, err := server.ParsePrefixes(proxyAddresses)
+	if err != nil {
+		return nil, sources.invalid("trusted-proxies", err)
+	}
+
+	switch strings.ToUpper(storageTypeString) {
+	case "FILE", "NONE":
+	default:
+		return nil, sources.invalid("storage", fmt.Errorf("unknown storage type %q", storageTypeString))
+	}
+
+	normalizationRules, err := normalize.ParseRules(normalization)
+	if err != nil {
+		return nil, sources.invalid("normalize", err)
+	}
+
+	for _, duration := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"archive-grace", archiveGracePeriod},
+		{"trash-retention", trashRetention},
+		{"popularity-half-life", popularityHalfLife},
+		{"shutdown-delay", shutdownDelay},
+		{"hsts-max-age", hstsMaxAge},
+	} {
+		if duration.value < 0 {
+			return nil, sources.invalid(duration.name, errors.New("must not be negative"))
+		}
+	}
+
+	if maxPathLength <= 0 {
+		return nil, sources.invalid("max-path-length", errors.New("must be a positive number"))
+	}
+
+	if searchLimit <= 0 {
+		return nil, sources.invalid("search-limit", errors.New("must be a positive number"))
+	}
+
+	if _, err := links.CompilePathChars(pathChars); err != nil {
+		return nil, sources.invalid("path-chars", err)
+	}
+
+	if port < 0 || port > 65535 {
+		return nil, sources.invalid("port", fmt.Errorf("%d is not a port number", port))
+	}
+
+	if adminAddress != "" {
+		if _, _, err := net.SplitHostPort(adminAddress); err != nil {
+			return nil, sources.invalid("admin-address", err)
+		}
+	}
+
+	socketMode, err := strconv.ParseUint(unixSocketMode, 8, 32)
+	if err != nil || socketMode > 0777 {
+		return nil, sources.invalid("unix-socket-mode", fmt.Errorf("%q is not an octal file mode", unixSocketMode))
+	}
+
+	if (tlsCert == "") != (tlsKey == "") {
+		return nil, sources.invalid("tls-cert", errors.New("-tls-cert and -tls-key must be set together"))
+	}
+	minVersion, err := server.ParseTLSVersion(tlsMinVersion)
+	if err != nil {
+		return nil, sources.invalid("tls-min-version", err)
+	}
+	if httpRedirectPort != 0 && tlsCert == "" {
+		return nil, sources.invalid("http-redirect-port", errors.New("requires -tls-cert"))
 	}
 
 	return &Config{
-		Port:        port,
-		StorageType: storage.FromString(storageTypeString),
-		ConfigFile:  configFile,
-		LogLevel:    level,
+		Port:               port,
+		StorageType:        storage.FromString(storageTypeString),
+		ConfigFile:         configFile,
+		LogLevel:           level,
+		ArchiveGracePeriod: archiveGracePeriod,
+		TrashRetention:     trashRetention,
+		Normalization:      normalizationRules,
+		PathChars:          pathChars,
+		MaxPathLength:      maxPathLength,
+		ReservedPaths:      splitList(reservedPaths),
+		TargetPolicy: links.TargetPolicy{
+			AllowedSchemes:        splitList(strings.ToLower(allowedSchemes)),
+			RequireAbsolute:       !allowRelativeTargets,
+			AllowedDomains:        splitList(allowedDomains),
+			DeniedDomains:         splitList(deniedDomains),
+			BlockPrivateAddresses: blockPrivateTargets,
+		},
+		InternalDomains:    splitList(internalDomains),
+		SearchMaxDistance:  searchMaxDistance,
+		SearchLimit:        searchLimit,
+		PopularityHalfLife: popularityHalfLife,
+		ShutdownDelay:      shutdownDelay,
+		TLSCert:            tlsCert,
+		TLSKey:             tlsKey,
+		TLSMinVersion:      minVersion,
+		HTTPRedirectPort:   httpRedirectPort,
+		HSTSMaxAge:         hstsMaxAge,
+		UnixSocket:         unixSocket,
+		UnixSocketMode:     fs.FileMode(socketMode),
+		AdminAddress:       adminAddress,
+		LogFormat:          logFormat,
+		RedirectLogSample:  redirectLogSample,
+		TrustedProxies:     proxies,
+		TrustUnixSocket:    trustUnixSocket,
+		Tracing:            tracingExporter,
+		RedirectRateLimit:  redirectRate,
+		SearchRateLimit:    searchRate,
+		MutationRateLimit:  mutationRate,
+	}, nil
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
 	}
+	return items
 }
@@ -0,0 +1,224 @@
+package config
+
+import (
+	"crypto/tls"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeServerConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "golinks.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func env(vars map[string]string) func(string) string {
+	return func(name string) string {
+		return vars[name]
+	}
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	serverConfig := writeServerConfig(t, `
+port: 9000
+search-limit: 5
+trash-retention: 48h
+internal-domains:
+  - example.com
+  - example.org
+block-private-targets: true
+`)
+
+	cfg, err := Load(
+		[]string{"-port", "8081"},
+		env(map[string]string{
+			"GOLINKS_SERVER_CONFIG": serverConfig,
+			"GOLINKS_PORT":          "8082",
+			"GOLINKS_SEARCH_LIMIT":  "7",
+		}),
+	)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if cfg.Port != 8081 {
+		t.Errorf("Port = %d, want the flag's 8081", cfg.Port)
+	}
+	if cfg.SearchLimit != 7 {
+		t.Errorf("SearchLimit = %d, want the environment's 7", cfg.SearchLimit)
+	}
+	if cfg.TrashRetention != 48*time.Hour {
+		t.Errorf("TrashRetention = %v, want the file's 48h", cfg.TrashRetention)
+	}
+	if !reflect.DeepEqual(cfg.InternalDomains, []string{"example.com", "example.org"}) {
+		t.Errorf("InternalDomains = %v, want the file's list", cfg.InternalDomains)
+	}
+	if !cfg.TargetPolicy.BlockPrivateAddresses {
+		t.Error("BlockPrivateAddresses = false, want the file's true")
+	}
+	if cfg.ArchiveGracePeriod != 7*24*time.Hour {
+		t.Errorf("ArchiveGracePeriod = %v, want the default 168h", cfg.ArchiveGracePeriod)
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	tests := []struct {
+		name         string
+		args         []string
+		env          map[string]string
+		serverConfig string
+		want         string
+	}{
+		{
+			name: "Invalid flag",
+			args: []string{"-search-limit", "0"},
+			want: "invalid -search-limit",
+		},
+		{
+			name: "Unknown flag",
+			args: []string{"-prot", "80"},
+			want: "flag provided but not defined: -prot",
+		},
+		{
+			name: "Malformed flag value",
+			args: []string{"-port", "eighty"},
+			want: "invalid value \"eighty\" for flag -port",
+		},
+		{
+			name: "Negative archive grace period",
+			args: []string{"-archive-grace", "-1h"},
+			want: "invalid -archive-grace",
+		},
+		{
+			name: "Negative trash retention",
+			env:  map[string]string{"GOLINKS_TRASH_RETENTION": "-24h"},
+			want: "invalid GOLINKS_TRASH_RETENTION",
+		},
+		{
+			name: "Negative popularity half-life",
+			args: []string{"-popularity-half-life", "-1s"},
+			want: "invalid -popularity-half-life",
+		},
+		{
+			name:         "Negative shutdown delay",
+			serverConfig: "shutdown-delay: -5s\n",
+			want:         "invalid shutdown-delay in ",
+		},
+		{
+			name: "Negative HSTS max age",
+			args: []string{"-hsts-max-age", "-1h"},
+			want: "invalid -hsts-max-age",
+		},
+		{
+			name: "Zero max path length",
+			args: []string{"-max-path-length", "0"},
+			want: "invalid -max-path-length",
+		},
+		{
+			name: "Negative max path length",
+			env:  map[string]string{"GOLINKS_MAX_PATH_LENGTH": "-3"},
+			want: "invalid GOLINKS_MAX_PATH_LENGTH",
+		},
+		{
+			name: "Invalid environment variable",
+			env:  map[string]string{"GOLINKS_TRASH_RETENTION": "forever"},
+			want: "invalid GOLINKS_TRASH_RETENTION",
+		},
+		{
+			name: "Invalid value after parsing",
+			env:  map[string]string{"GOLINKS_STORAGE": "S3"},
+			want: "invalid GOLINKS_STORAGE",
+		},
+		{
+			name:         "Invalid file value",
+			serverConfig: "port: eighty\n",
+			want:         "invalid port in ",
+		},
+		{
+			name:         "Unknown file key",
+			serverConfig: "prot: 80\n",
+			want:         "unknown key prot",
+		},
+		{
+			name:         "Server config in the server config",
+			serverConfig: "server-config: other.yaml\n",
+			want:         "unknown key server-config",
+		},
+		{
+			name:         "Mapping value",
+			serverConfig: "normalize:\n  case: true\n",
+			want:         "invalid normalize",
+		},
+		{
+			name: "Missing server config",
+			env:  map[string]string{"GOLINKS_SERVER_CONFIG": "/nonexistent/golinks.yaml"},
+			want: "reading server config",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vars := map[string]string{}
+			for name, value := range tt.env {
+				vars[name] = value
+			}
+			if tt.serverConfig != "" {
+				vars["GOLINKS_SERVER_CONFIG"] = writeServerConfig(t, tt.serverConfig)
+			}
+
+			_, err := Load(tt.args, env(vars))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Load() error = %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoad_FileValuesKeepTheirText(t *testing.T) {
+	serverConfig := writeServerConfig(t, `
+unix-socket-mode: 0660
+tls-min-version: 1.0
+reserved-paths:
+`)
+
+	cfg, err := Load(nil, env(map[string]string{"GOLINKS_SERVER_CONFIG": serverConfig}))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.UnixSocketMode != 0660 {
+		t.Errorf("UnixSocketMode = %o, want 660", cfg.UnixSocketMode)
+	}
+	if cfg.TLSMinVersion != tls.VersionTLS10 {
+		t.Errorf("TLSMinVersion = %x, want TLS 1.0", cfg.TLSMinVersion)
+	}
+	if len(cfg.ReservedPaths) != 0 {
+		t.Errorf("ReservedPaths = %v, want none for an empty value", cfg.ReservedPaths)
+	}
+}
+
+func TestLoad_Help(t *testing.T) {
+	if _, err := Load([]string{"-h"}, env(nil)); !errors.Is(err, flag.ErrHelp) {
+		t.Errorf("Load(-h) error = %v, want %v", err, flag.ErrHelp)
+	}
+}
+
+func TestLoad_TrustedProxies(t *testing.T) {
+	cfg, err := Load([]string{"-trusted-proxies", "10.0.0.0/8,unix"}, env(nil))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(cfg.TrustedProxies) != 1 || cfg.TrustedProxies[0].String() != "10.0.0.0/8" {
+		t.Errorf("TrustedProxies = %v, want [10.0.0.0/8]", cfg.TrustedProxies)
+	}
+	if !cfg.TrustUnixSocket {
+		t.Error("TrustUnixSocket = false, want true")
+	}
+}
@@ -0,0 +1,144 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// envPrefix starts the name of the environment variable for each setting.
+	envPrefix = "GOLINKS_"
+	// serverConfigKey names the setting holding the path of the server config
+	// file, which can only be set by flag or environment variable.
+	serverConfigKey = "server-config"
+)
+
+// sources records where each setting that is not a default was taken from, so
+// that errors can point at it.
+type sources map[string]string
+
+// invalid returns an error naming the setting key and where it came from.
+func (s sources) invalid(key string, err error) error {
+	source, exists := s[key]
+	if !exists {
+		source = "-" + key
+	}
+	return fmt.Errorf("invalid %s: %w", source, err)
+}
+
+// applySources sets every flag that was not given on the command line from
+// its environment variable or, failing that, from the server config file.
+func applySources(flags *flag.FlagSet, getenv func(string) string) (sources, error) {
+	src := make(sources)
+	flags.Visit(func(f *flag.Flag) {
+		src[f.Name] = "-" + f.Name
+	})
+
+	if _, fromFlag := src[serverConfigKey]; !fromFlag {
+		if value := getenv(envName(serverConfigKey)); value != "" {
+			flags.Set(serverConfigKey, value)
+			src[serverConfigKey] = envName(serverConfigKey)
+		}
+	}
+
+	var fileValues map[string]string
+	serverConfig := flags.Lookup(serverConfigKey).Value.String()
+	if serverConfig != "" {
+		var err error
+		fileValues, err = readServerConfig(serverConfig, flags)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var err error
+	flags.VisitAll(func(f *flag.Flag) {
+		if _, set := src[f.Name]; set || err != nil || f.Name == serverConfigKey {
+			return
+		}
+
+		var value, source string
+		if envValue := getenv(envName(f.Name)); envValue != "" {
+			value, source = envValue, envName(f.Name)
+		} else if fileValue, exists := fileValues[f.Name]; exists {
+			value, source = fileValue, fmt.Sprintf("%s in %s", f.Name, serverConfig)
+		} else {
+			return
+		}
+
+		src[f.Name] = source
+		if setErr := f.Value.Set(value); setErr != nil {
+			err = src.invalid(f.Name, setErr)
+		}
+	})
+	return src, err
+}
+
+// envName returns the environment variable for a setting, such as
+// GOLINKS_SEARCH_LIMIT for search-limit.
+func envName(key string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(key, "-", "_"))
+}
+
+// readServerConfig reads a YAML server config file. Its keys are the names of
+// the flags, and lists can be given as YAML sequences wherever a flag takes a
+// comma separated list:
+//
+//	port: 80
+//	storage: FILE
+//	config: /var/lib/golinks/links
+//	internal-domains:
+//	  - example.com
+//	  - example.org
+func readServerConfig(path string, flags *flag.FlagSet) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading server config: %w", err)
+	}
+
+	// Values are read as nodes so that each keeps the text it was written as:
+	// decoding them would turn a mode such as 0660 into the number 432, and a
+	// version such as 1.0 into 1.
+	var raw map[string]yaml.Node
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parsing server config %s: %w", path, err)
+	}
+
+	values := make(map[string]string, len(raw))
+	for key, node := range raw {
+		if key == serverConfigKey || flags.Lookup(key) == nil {
+			return nil, fmt.Errorf("unknown key %s in %s", key, path)
+		}
+		switch node.Kind {
+		case yaml.ScalarNode:
+			values[key] = scalarValue(&node)
+		case yaml.SequenceNode:
+			items := make([]string, len(node.Content))
+			for i, item := range node.Content {
+				if item.Kind != yaml.ScalarNode {
+					return nil, fmt.Errorf("invalid %s in %s: expected a list of values", key, path)
+				}
+				items[i] = scalarValue(item)
+			}
+			values[key] = strings.Join(items, ",")
+		case yaml.MappingNode:
+			return nil, fmt.Errorf("invalid %s in %s: expected a value, got a mapping", key, path)
+		default:
+			return nil, fmt.Errorf("invalid %s in %s: expected a value", key, path)
+		}
+	}
+	return values, nil
+}
+
+// scalarValue returns the text of a scalar node as it was written, or nothing
+// for null.
+func scalarValue(node *yaml.Node) string {
+	if node.Tag == "!!null" {
+		return ""
+	}
+	return node.Value
+}
@@ -0,0 +1,151 @@
+package handler
+
+import (
+	"github.com/dfryer1193/golinks/models"
+	"net/url"
+	"strings"
+)
+
+// Actions passed to Alfred workflows in the "action" variable, so that a
+// workflow can tell what the user picked.
+const (
+	alfredActionOpen   = "open"
+	alfredActionCopy   = "copy"
+	alfredActionEdit   = "edit"
+	alfredActionCreate = "create"
+)
+
+// alfredResponse is an Alfred Script Filter response.
+type alfredResponse struct {
+	Variables map[string]string `json:"variables,omitempty"`
+	Items     []alfredItem      `json:"items"`
+}
+
+type alfredItem struct {
+	Uid          string               `json:"uid,omitempty"`
+	ObjType      string               `json:"type"`
+	Title        string               `json:"title"`
+	Subtitle     string               `json:"subtitle"`
+	Arg          string               `json:"arg"`
+	Autocomplete string               `json:"autocomplete,omitempty"`
+	Match        string               `json:"match,omitempty"`
+	QuicklookURL string               `json:"quicklookurl,omitempty"`
+	Text         *alfredText          `json:"text,omitempty"`
+	Mods         map[string]alfredMod `json:"mods,omitempty"`
+	Variables    map[string]string    `json:"variables,omitempty"`
+}
+
+// alfredText is what Alfred copies with ⌘C and shows with ⌘L.
+type alfredText struct {
+	Copy      string `json:"copy"`
+	LargeType string `json:"largetype"`
+}
+
+// alfredMod replaces the action of an item while a modifier key is held.
+type alfredMod struct {
+	Valid     bool              `json:"valid"`
+	Arg       string            `json:"arg"`
+	Subtitle  string            `json:"subtitle"`
+	Variables map[string]string `json:"variables,omitempty"`
+}
+
+// buildAlfredResponse lists entries as Alfred items, in order. Actioning an
+// item follows the short link, so that the visit is counted; holding ⌘ copies
+// the short link instead and holding ⌥ opens its edit page. If there are no
+// entries, a single item offers to create a link for the query.
+func buildAlfredResponse(entries []*models.Entry, query string, base string) *alfredResponse {
+	host := hostOf(base)
+	items := make([]alfredItem, 0, len(entries))
+	for _, entry := range entries {
+		shortLink := base + "/" + url.PathEscape(entry.Path)
+		editLink := editURL(base, entry.Path)
+		items = append(items, alfredItem{
+			Uid:          entry.Path,
+			ObjType:      "default",
+			Title:        entry.Path,
+			Subtitle:     entry.Target,
+			Arg:          shortLink,
+			Autocomplete: entry.Path,
+			Match:        alfredMatch(entry),
+			QuicklookURL: entry.Target,
+			Text: &alfredText{
+				Copy:      shortLink,
+				LargeType: host + "/" + entry.Path,
+			},
+			Mods: map[string]alfredMod{
+				"cmd": {
+					Valid:     true,
+					Arg:       shortLink,
+					Subtitle:  "Copy " + host + "/" + entry.Path,
+					Variables: map[string]string{"action": alfredActionCopy},
+				},
+				"alt": {
+					Valid:     true,
+					Arg:       editLink,
+					Subtitle:  "Edit " + host + "/" + entry.Path,
+					Variables: map[string]string{"action": alfredActionEdit},
+				},
+			},
+			Variables: map[string]string{"action": alfredActionOpen},
+		})
+	}
+
+	if len(items) == 0 {
+		path := strings.TrimSpace(query)
+		title := "Create a link"
+		if path != "" {
+			title = "Create " + host + "/" + path
+		}
+		items = append(items, alfredItem{
+			ObjType:   "default",
+			Title:     title,
+			Subtitle:  "No link matches, open the form to add one",
+			Arg:       editURL(base, path),
+			Variables: map[string]string{"action": alfredActionCreate},
+		})
+	}
+
+	return &alfredResponse{
+		Variables: map[string]string{"golinksURL": base},
+		Items:     items,
+	}
+}
+
+// alfredMatch returns the words Alfred filters an entry by, when a workflow
+// lets Alfred filter results itself.
+func alfredMatch(entry *models.Entry) string {
+	words := []string{entry.Path}
+	words = append(words, tokenizeWords(entry.Path)...)
+	words = append(words, entry.Tags...)
+	words = append(words, strings.Fields(entry.Description)...)
+	return strings.Join(words, " ")
+}
+
+// tokenizeWords splits a path into the words between its separators.
+func tokenizeWords(path string) []string {
+	words := strings.FieldsFunc(path, func(r rune) bool {
+		return r == '-' || r == '_' || r == '.' || r == '~'
+	})
+	if len(words) == 1 {
+		return nil
+	}
+	return words
+}
+
+// editURL returns the address of the form for creating or editing the link at
+// path.
+func editURL(base string, path string) string {
+	if path == "" {
+		return base + "/update"
+	}
+	return base + "/update?path=" + url.QueryEscape(path)
+}
+
+// hostOf returns the host of a base URL, for showing short links the way
+// people type them.
+func hostOf(base string) string {
+	if _, host, found := strings.Cut(base, "://"); found {
+		return host
+	}
+	return base
+}
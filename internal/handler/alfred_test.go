@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/dfryer1193/golinks/internal/links"
+	"github.com/dfryer1193/golinks/internal/links/storage"
+	"github.com/dfryer1193/golinks/internal/search"
+	"github.com/dfryer1193/golinks/models"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func alfredSearch(t *testing.T, h *ApiHandler, query string) map[string]any {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "http://go/api/v1/search?isAlfred=true&query="+query, nil)
+	rec := httptest.NewRecorder()
+	h.search(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	var resp map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	return resp
+}
+
+func TestApiHandler_SearchForAlfred(t *testing.T) {
+	linkMap := links.NewLinkMap(storage.NONE, "")
+	linkMap.Put(context.Background(), &models.Entry{
+		Path:        "on-call",
+		Target:      "https://pager.example.com",
+		Description: "Pager schedule",
+		Tags:        []string{"ops"},
+	})
+	linkMap.Put(context.Background(), &models.Entry{Path: "on-call-docs", Target: "https://docs.example.com/oncall"})
+	h := NewApiHandler(linkMap, search.DefaultOptions())
+
+	resp := alfredSearch(t, h, "on-call")
+	if got := resp["variables"]; !reflect.DeepEqual(got, map[string]any{"golinksURL": "http://go"}) {
+		t.Errorf("variables = %v", got)
+	}
+
+	items := resp["items"].([]any)
+	if len(items) != 2 {
+		t.Fatalf("got %d items, want 2: %v", len(items), items)
+	}
+	want := map[string]any{
+		"uid":          "on-call",
+		"type":         "default",
+		"title":        "on-call",
+		"subtitle":     "https://pager.example.com",
+		"arg":          "http://go/on-call",
+		"autocomplete": "on-call",
+		"match":        "on-call on call ops Pager schedule",
+		"quicklookurl": "https://pager.example.com",
+		"text": map[string]any{
+			"copy":      "http://go/on-call",
+			"largetype": "go/on-call",
+		},
+		"mods": map[string]any{
+			"cmd": map[string]any{
+				"valid":     true,
+				"arg":       "http://go/on-call",
+				"subtitle":  "Copy go/on-call",
+				"variables": map[string]any{"action": "copy"},
+			},
+			"alt": map[string]any{
+				"valid":     true,
+				"arg":       "http://go/update?path=on-call",
+				"subtitle":  "Edit go/on-call",
+				"variables": map[string]any{"action": "edit"},
+			},
+		},
+		"variables": map[string]any{"action": "open"},
+	}
+	if !reflect.DeepEqual(items[0], want) {
+		t.Errorf("first item = %v, want %v", items[0], want)
+	}
+	if title := items[1].(map[string]any)["title"]; title != "on-call-docs" {
+		t.Errorf("second item title = %v, want on-call-docs", title)
+	}
+}
+
+func TestApiHandler_SearchForAlfredOffersToCreate(t *testing.T) {
+	linkMap := links.NewLinkMap(storage.NONE, "")
+	linkMap.Put(context.Background(), &models.Entry{Path: "wiki", Target: "https://wiki.example.com"})
+	h := NewApiHandler(linkMap, search.DefaultOptions())
+
+	items := alfredSearch(t, h, "standup")["items"].([]any)
+	want := []any{map[string]any{
+		"type":      "default",
+		"title":     "Create go/standup",
+		"subtitle":  "No link matches, open the form to add one",
+		"arg":       "http://go/update?path=standup",
+		"variables": map[string]any{"action": "create"},
+	}}
+	if !reflect.DeepEqual(items, want) {
+		t.Errorf("items = %v, want %v", items, want)
+	}
+}
+
+func TestApiHandler_GetAllForAlfred(t *testing.T) {
+	linkMap := links.NewLinkMap(storage.NONE, "")
+	linkMap.Put(context.Background(), &models.Entry{Path: "wiki", Target: "https://wiki.example.com"})
+	linkMap.Put(context.Background(), &models.Entry{Path: "docs", Target: "https://docs.example.com"})
+	h := NewApiHandler(linkMap, search.DefaultOptions())
+
+	req := httptest.NewRequest(http.MethodGet, "http://go/api/v1/all/alfred", nil)
+	rec := httptest.NewRecorder()
+	h.getAllForAlfred(rec, req)
+
+	var resp alfredResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	var titles []string
+	for _, item := range resp.Items {
+		titles = append(titles, item.Title)
+	}
+	if !reflect.DeepEqual(titles, []string{"docs", "wiki"}) {
+		t.Errorf("titles = %v, want [docs wiki]", titles)
+	}
+}
@@ -1,82 +1,114 @@
 package handler
 
 import (
+	"errors"
 	"fmt"
 	"github.com/dfryer1193/golinks/internal/links"
+	"github.com/dfryer1193/golinks/internal/links/storage"
 	"github.com/dfryer1193/golinks/internal/search"
 	"github.com/dfryer1193/golinks/models"
 	"github.com/dfryer1193/mjolnir/middleware"
 	"github.com/dfryer1193/mjolnir/utils"
 	"github.com/go-chi/chi/v5"
+	"maps"
 	"mime"
 	"net/http"
-	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 )
 
-type alfredItem struct {
-	Uid          string `json:"uid"`
-	ObjType      string `json:"type"`
-	Title        string `json:"title"`
-	Subtitle     string `json:"subtitle"`
-	Arg          string `json:"arg"`
-	Autocomplete string `json:"autocomplete"`
+// linkConflictErrors are errors returned by the LinkMap when a request
+// conflicts with an existing link.
+var linkConflictErrors = []error{
+	links.ErrPathConflict,
+	links.ErrHasAliases,
 }
 
-type alfredResponse struct {
-	Items []alfredItem `json:"items"`
+// validationErrorResponse extends the standard error response with the
+// machine-readable details of a links.ValidationError.
+type validationErrorResponse struct {
+	Error  string `json:"error"`
+	Code   int    `json:"code"`
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+	Path   string `json:"path,omitempty"`
+	Line   int    `json:"line,omitempty"`
 }
 
 type ApiHandler struct {
-	linkMap *links.LinkMap
+	linkMap       *links.LinkMap
+	searchOptions search.Options
 }
 
-func NewApiHandler(linkMap *links.LinkMap) *ApiHandler {
-	return &ApiHandler{linkMap: linkMap}
+func NewApiHandler(linkMap *links.LinkMap, searchOptions search.Options) *ApiHandler {
+	return &ApiHandler{linkMap: linkMap, searchOptions: searchOptions}
 }
 
 func (h *ApiHandler) postLink(w http.ResponseWriter, r *http.Request) {
 	path := chi.URLParam(r, "path")
 	target := &struct {
-		Target string `json:"target"`
+		Target      string              `json:"target"`
+		AliasOf     string              `json:"aliasOf"`
+		Description string              `json:"description"`
+		Owner       string              `json:"owner"`
+		Tags        []string            `json:"tags"`
+		Redirect    models.RedirectMode `json:"redirect"`
+		ActiveFrom  *time.Time          `json:"activeFrom"`
+		ExpiresAt   *time.Time          `json:"expiresAt"`
 	}{}
 	err := utils.DecodeJSON(r, target)
 	if err != nil {
 		middleware.SetError(r, http.StatusBadRequest, fmt.Errorf("invalid target: %w", err))
 		return
 	}
+
 	newEntry := &models.Entry{
-		Path:   path,
-		Target: target.Target,
+		Path:        path,
+		Target:      strings.TrimSpace(target.Target),
+		AliasOf:     target.AliasOf,
+		Description: strings.TrimSpace(target.Description),
+		Owner:       strings.TrimSpace(target.Owner),
+		Tags:        target.Tags,
+		Redirect:    target.Redirect,
+		ActiveFrom:  target.ActiveFrom,
+		ExpiresAt:   target.ExpiresAt,
 	}
 
-	targetUrl, err := url.Parse(target.Target)
+	oldEntry, exists := h.linkMap.GetEntry(path)
+	if exists { //TODO: Move this check inside the LinkMap, return delta from update fn
+		err = h.linkMap.Update(r.Context(), newEntry)
+	} else {
+		err = h.linkMap.Put(r.Context(), newEntry)
+	}
+	var validationErr *links.ValidationError
+	if errors.As(err, &validationErr) {
+		respondValidationError(w, r, validationErr)
+		return
+	}
+	if isAnyOf(err, linkConflictErrors) {
+		middleware.SetError(r, http.StatusConflict, err)
+		return
+	}
+	if errors.Is(err, links.ErrClosed) {
+		middleware.SetError(r, http.StatusServiceUnavailable, err)
+		return
+	}
 	if err != nil {
-		middleware.SetError(r, http.StatusBadRequest, fmt.Errorf("target %s is not a valid url", target.Target))
+		middleware.SetError(r, http.StatusInternalServerError, fmt.Errorf("error saving link %s: %w", newEntry.Path, err))
 		return
 	}
 
-	var oldEntry *models.Entry
-	oldTarget, exists := h.linkMap.Get(path)
-	if exists { //TODO: Move this check inside the LinkMap, return delta from update fn
-		oldEntry = &models.Entry{
-			Path:   path,
-			Target: oldTarget,
-		}
-
-		if err := h.linkMap.Update(newEntry.Path, targetUrl); err != nil {
-			middleware.SetError(r, http.StatusInternalServerError, fmt.Errorf("error updating link %s: %w", newEntry.Path, err))
-			return
-		}
-	} else {
-		if err := h.linkMap.Put(path, targetUrl); err != nil {
-			middleware.SetError(r, http.StatusInternalServerError, fmt.Errorf("error adding link %s: %w", newEntry.Path, err))
-			return
-		}
+	// Respond with the link as it was stored, with the timestamps the LinkMap
+	// filled in.
+	storedEntry, exists := h.linkMap.GetEntry(path)
+	if !exists {
+		storedEntry = newEntry
 	}
-
 	update := models.UpdateDelta{
 		Old: oldEntry,
-		New: newEntry,
+		New: storedEntry,
 	}
 
 	utils.RespondJSON(w, r, http.StatusOK, update)
@@ -84,7 +116,16 @@ func (h *ApiHandler) postLink(w http.ResponseWriter, r *http.Request) {
 
 func (h *ApiHandler) deleteLink(w http.ResponseWriter, r *http.Request) {
 	path := chi.URLParam(r, "path")
-	if err := h.linkMap.Delete(path); err != nil {
+	err := h.linkMap.Delete(r.Context(), path)
+	if isAnyOf(err, linkConflictErrors) {
+		middleware.SetError(r, http.StatusConflict, fmt.Errorf("cannot delete %s: %w", path, err))
+		return
+	}
+	if errors.Is(err, links.ErrClosed) {
+		middleware.SetError(r, http.StatusServiceUnavailable, err)
+		return
+	}
+	if err != nil {
 		middleware.SetError(r, http.StatusInternalServerError, fmt.Errorf("error deleting link %s: %w", path, err))
 		return
 	}
@@ -97,44 +138,79 @@ func (h *ApiHandler) getAll(w http.ResponseWriter, r *http.Request) {
 	utils.RespondJSON(w, r, http.StatusOK, allLinks)
 }
 
+func (h *ApiHandler) getTrash(w http.ResponseWriter, r *http.Request) {
+	utils.RespondJSON(w, r, http.StatusOK, h.linkMap.GetTrash())
+}
+
+func (h *ApiHandler) restoreLink(w http.ResponseWriter, r *http.Request) {
+	path := chi.URLParam(r, "path")
+	restored, err := h.linkMap.Restore(r.Context(), path)
+	var validationErr *links.ValidationError
+	switch {
+	case errors.Is(err, links.ErrNotInTrash):
+		middleware.SetNotFoundError(r, fmt.Errorf("path %s is not in the trash", path))
+		return
+	case errors.Is(err, links.ErrLinkExists):
+		middleware.SetError(r, http.StatusConflict, fmt.Errorf("cannot restore %s: %w", path, err))
+		return
+	case errors.As(err, &validationErr):
+		respondValidationError(w, r, validationErr)
+		return
+	case errors.Is(err, links.ErrClosed):
+		middleware.SetError(r, http.StatusServiceUnavailable, err)
+		return
+	case err != nil:
+		middleware.SetInternalError(r, fmt.Errorf("error restoring link %s: %w", path, err))
+		return
+	}
+
+	utils.RespondJSON(w, r, http.StatusOK, restored)
+}
+
 func (h *ApiHandler) getAllForAlfred(w http.ResponseWriter, r *http.Request) {
-	alfredResponse := buildAlfredResponse(h.linkMap.GetAll())
+	entries := slices.SortedFunc(maps.Values(h.linkMap.GetAll()), func(a, b *models.Entry) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+	alfredResponse := buildAlfredResponse(entries, "", baseURL(r))
 	utils.RespondJSON(w, r, http.StatusOK, alfredResponse)
 }
 
 func (h *ApiHandler) getLink(w http.ResponseWriter, r *http.Request) {
 	path := chi.URLParam(r, "path")
-	target, exists := h.linkMap.Get(path)
+	entry, exists := h.linkMap.GetEntry(path)
 	if !exists {
 		middleware.SetError(r, http.StatusNotFound, fmt.Errorf("path %s has no target", path))
 		return
 	}
 
-	utils.RespondJSON(w, r, http.StatusOK, models.Entry{
-		Path:   path,
-		Target: target,
-	})
+	utils.RespondJSON(w, r, http.StatusOK, entry)
 }
 
+// search returns the links matching the query, best match first. The number of
+// results can be set with the limit parameter.
 func (h *ApiHandler) search(w http.ResponseWriter, r *http.Request) {
-	options := h.linkMap.GetAllKeys()
 	query := r.URL.Query().Get("query")
 	isAlfredRequest := r.URL.Query().Get("isAlfred") == "true"
-	hits := search.StringSearch(query, options)
-	keyHits := make([]string, len(hits))
-	for i, hit := range hits {
-		keyHits[i] = hit.Value
+
+	opts := h.searchOptions
+	if rawLimit := r.URL.Query().Get("limit"); rawLimit != "" {
+		limit, err := strconv.Atoi(rawLimit)
+		if err != nil || limit <= 0 {
+			middleware.SetBadRequestError(r, fmt.Errorf("invalid limit %q: must be a positive number", rawLimit))
+			return
+		}
+		opts.Limit = limit
 	}
 
-	hitMap := h.linkMap.GetFiltered(keyHits)
+	results := h.linkMap.Search(r.Context(), query, opts)
 
 	if isAlfredRequest {
-		resp := buildAlfredResponse(hitMap)
+		resp := buildAlfredResponse(results, query, baseURL(r))
 		utils.RespondJSON(w, r, http.StatusOK, resp)
 		return
 	}
 
-	utils.RespondJSON(w, r, http.StatusOK, hitMap)
+	utils.RespondJSON(w, r, http.StatusOK, results)
 }
 
 func (h *ApiHandler) exportLinks(w http.ResponseWriter, r *http.Request) {
@@ -143,8 +219,8 @@ func (h *ApiHandler) exportLinks(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/plain")
 	w.Header().Set("Content-Disposition", "attachment; filename=links")
 
-	for path, target := range allLinks {
-		_, err := fmt.Fprintf(w, "%s %s\n", path, target)
+	for _, entry := range allLinks {
+		_, err := fmt.Fprintln(w, storage.FormatEntry(entry))
 		if err != nil {
 			middleware.SetError(r, http.StatusInternalServerError, fmt.Errorf("error writing export file: %w", err))
 		}
@@ -163,7 +239,16 @@ func (h *ApiHandler) importLinks(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = h.linkMap.ReplaceAll(r.Body)
+	err = h.linkMap.ReplaceAll(r.Context(), r.Body)
+	var validationErr *links.ValidationError
+	if errors.As(err, &validationErr) {
+		respondValidationError(w, r, validationErr)
+		return
+	}
+	if errors.Is(err, links.ErrClosed) {
+		middleware.SetError(r, http.StatusServiceUnavailable, err)
+		return
+	}
 	if err != nil {
 		middleware.SetInternalError(r, fmt.Errorf("error importing links: %w", err))
 		return
@@ -171,20 +256,22 @@ func (h *ApiHandler) importLinks(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
-func buildAlfredResponse(mapItems map[string]string) *alfredResponse {
-	items := make([]alfredItem, len(mapItems))
-	for key, val := range mapItems {
-		item := alfredItem{
-			Uid:          key,
-			Title:        key,
-			Subtitle:     val,
-			Arg:          val,
-			Autocomplete: key,
-		}
-		items = append(items, item)
-	}
+func respondValidationError(w http.ResponseWriter, r *http.Request, err *links.ValidationError) {
+	utils.RespondJSON(w, r, http.StatusUnprocessableEntity, validationErrorResponse{
+		Error:  err.Error(),
+		Code:   http.StatusUnprocessableEntity,
+		Field:  err.Field,
+		Reason: err.Reason,
+		Path:   err.Path,
+		Line:   err.Line,
+	})
+}
 
-	return &alfredResponse{
-		Items: items,
+func isAnyOf(err error, targets []error) bool {
+	for _, target := range targets {
+		if errors.Is(err, target) {
+			return true
+		}
 	}
+	return false
 }
@@ -0,0 +1,182 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/dfryer1193/golinks/internal/links"
+	"github.com/dfryer1193/golinks/internal/links/storage"
+	"github.com/dfryer1193/golinks/internal/search"
+	"github.com/dfryer1193/golinks/models"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestApiHandler_ImportRejectsInvalidLinks(t *testing.T) {
+	linkMap := links.NewLinkMap(storage.NONE, "")
+	linkMap.Put(context.Background(), &models.Entry{Path: "wiki", Target: "https://wiki.com"})
+	h := NewApiHandler(linkMap, search.DefaultOptions())
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/import", strings.NewReader("docs https://docs.com\nxss javascript:alert(1)\n"))
+	req.Header.Set("Content-Type", "text/plain")
+	rec := httptest.NewRecorder()
+	h.importLinks(rec, req)
+
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusUnprocessableEntity)
+	}
+	var got validationErrorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if got.Path != "xss" || got.Line != 2 || got.Field != "target" || got.Reason != links.ReasonSchemeNotAllowed {
+		t.Errorf("response = %+v, want line 2 (xss) target/%s", got, links.ReasonSchemeNotAllowed)
+	}
+	if _, exists := linkMap.Get("wiki"); !exists {
+		t.Error("a rejected import should leave the existing links in place")
+	}
+}
+
+func TestApiHandler_ImportRejectsMalformedLines(t *testing.T) {
+	linkMap := links.NewLinkMap(storage.NONE, "")
+	h := NewApiHandler(linkMap, search.DefaultOptions())
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/import", strings.NewReader("docs https://docs.com\n\ndocs https://docs.com expiresAt=tomorrow\n"))
+	req.Header.Set("Content-Type", "text/plain")
+	rec := httptest.NewRecorder()
+	h.importLinks(rec, req)
+
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusUnprocessableEntity)
+	}
+	var got validationErrorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if got.Line != 3 || got.Reason != links.ReasonMalformed {
+		t.Errorf("response = %+v, want line 3/%s", got, links.ReasonMalformed)
+	}
+}
+
+func TestApiHandler_PostLinkRespondsWithStoredEntry(t *testing.T) {
+	linkMap := links.NewLinkMap(storage.NONE, "")
+	h := NewApiHandler(linkMap, search.DefaultOptions())
+	router := chi.NewRouter()
+	router.Post("/api/v1/links/{path}", h.postLink)
+
+	post := func(target string) models.UpdateDelta {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/links/docs", strings.NewReader(`{"target":"`+target+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("POST status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+		}
+		var delta models.UpdateDelta
+		if err := json.NewDecoder(rec.Body).Decode(&delta); err != nil {
+			t.Fatalf("decoding response: %v", err)
+		}
+		return delta
+	}
+
+	created := post("https://docs.com")
+	if created.Old != nil {
+		t.Errorf("creating a link: Old = %+v, want nil", created.Old)
+	}
+	if created.New == nil || created.New.CreatedAt == nil || created.New.UpdatedAt == nil {
+		t.Fatalf("creating a link: New = %+v, want createdAt and updatedAt", created.New)
+	}
+
+	updated := post("https://new.docs.com")
+	if updated.New.Target != "https://new.docs.com" || updated.New.UpdatedAt == nil {
+		t.Errorf("updating a link: New = %+v, want the new target with updatedAt", updated.New)
+	}
+	if updated.New.CreatedAt == nil || !updated.New.CreatedAt.Equal(*created.New.CreatedAt) {
+		t.Errorf("updating a link: createdAt = %v, want %v", updated.New.CreatedAt, created.New.CreatedAt)
+	}
+}
+
+func TestApiHandler_PostLinkRejectsInvalidSchedulesAndAliases(t *testing.T) {
+	linkMap := links.NewLinkMap(storage.NONE, "")
+	linkMap.Put(context.Background(), &models.Entry{Path: "wiki", Target: "https://wiki.com"})
+	h := NewApiHandler(linkMap, search.DefaultOptions())
+	router := chi.NewRouter()
+	router.Post("/api/v1/links/{path}", h.postLink)
+
+	tests := []struct {
+		name       string
+		path       string
+		body       string
+		wantField  string
+		wantReason string
+	}{
+		{
+			name:       "Schedule that ends before it starts",
+			path:       "docs",
+			body:       `{"target":"https://docs.com","activeFrom":"2030-01-02T00:00:00Z","expiresAt":"2030-01-01T00:00:00Z"}`,
+			wantField:  "schedule",
+			wantReason: links.ReasonInvalidSchedule,
+		},
+		{
+			name:       "Alias of a missing link",
+			path:       "docs",
+			body:       `{"aliasOf":"missing"}`,
+			wantField:  "aliasOf",
+			wantReason: links.ReasonInvalidAlias,
+		},
+		{
+			name:       "Alias of itself",
+			path:       "wiki",
+			body:       `{"aliasOf":"wiki"}`,
+			wantField:  "aliasOf",
+			wantReason: links.ReasonInvalidAlias,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/links/"+tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusUnprocessableEntity {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusUnprocessableEntity, rec.Body)
+			}
+			var got validationErrorResponse
+			if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+				t.Fatalf("decoding response: %v", err)
+			}
+			if got.Field != tt.wantField || got.Reason != tt.wantReason {
+				t.Errorf("response = %+v, want %s/%s", got, tt.wantField, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestApiHandler_ImportRejectsLinesTooLongToRead(t *testing.T) {
+	linkMap := links.NewLinkMap(storage.NONE, "")
+	h := NewApiHandler(linkMap, search.DefaultOptions())
+
+	body := "docs https://docs.com\nlong https://long.com/" + strings.Repeat("a", 128*1024) + "\n"
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/import", strings.NewReader(body))
+	req.Header.Set("Content-Type", "text/plain")
+	rec := httptest.NewRecorder()
+	h.importLinks(rec, req)
+
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusUnprocessableEntity)
+	}
+	var got validationErrorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if got.Line != 2 || got.Reason != links.ReasonMalformed {
+		t.Errorf("response = %+v, want line 2/%s", got, links.ReasonMalformed)
+	}
+	if _, exists := linkMap.Get("docs"); exists {
+		t.Error("a rejected import should not bring in any links")
+	}
+}
@@ -1,28 +1,51 @@
 package handler
 
 import (
+	"bytes"
 	"embed"
 	"fmt"
+	"github.com/dfryer1193/golinks/models"
 	"github.com/dfryer1193/mjolnir/middleware"
+	"html/template"
 	"io"
 	"net/http"
+	"time"
 )
 
 //go:embed static/*
 var content embed.FS
 
+//go:embed templates/*
+var templateContent embed.FS
+
+var templates = template.Must(template.New("").Funcs(template.FuncMap{
+	"formatTime": formatTime,
+}).ParseFS(templateContent, "templates/*.html"))
+
+type interstitialData struct {
+	Path   string
+	Target string
+}
+
+type infoData struct {
+	Entry *models.Entry
+}
+
+type newFormData struct {
+	Path        string
+	Suggestions []*models.Entry
+}
+
 type ContentName int
 
 const (
 	INDEX ContentName = iota
-	NEW
 	STYLES
 	FAVICON
 )
 
 var staticContent = map[ContentName]string{
 	INDEX:   "static/index.html",
-	NEW:     "static/new.html",
 	STYLES:  "static/styles.css",
 	FAVICON: "static/favicon.ico",
 }
@@ -46,7 +69,49 @@ func (h *FrontendHandler) serveStyles(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *FrontendHandler) serveNewForm(w http.ResponseWriter, r *http.Request) {
-	serveEmbeddedContent(w, r, NEW)
+	renderTemplate(w, r, http.StatusOK, "new.html", newFormData{Path: r.URL.Query().Get("path")})
+}
+
+// serveNotFound offers to create a missing link, listing existing links the
+// visitor may have meant.
+func (h *FrontendHandler) serveNotFound(w http.ResponseWriter, r *http.Request, path string, suggestions []*models.Entry) {
+	renderTemplate(w, r, http.StatusNotFound, "new.html", newFormData{
+		Path:        path,
+		Suggestions: suggestions,
+	})
+}
+
+func (h *FrontendHandler) serveInterstitial(w http.ResponseWriter, r *http.Request, path string, target string) {
+	renderTemplate(w, r, http.StatusOK, "interstitial.html", interstitialData{
+		Path:   path,
+		Target: target,
+	})
+}
+
+func (h *FrontendHandler) serveInfo(w http.ResponseWriter, r *http.Request, entry *models.Entry) {
+	renderTemplate(w, r, http.StatusOK, "info.html", infoData{Entry: entry})
+}
+
+// renderTemplate renders into a buffer first so that a template error can
+// still be reported as an internal error.
+func renderTemplate(w http.ResponseWriter, r *http.Request, status int, name string, data any) {
+	var buf bytes.Buffer
+	if err := templates.ExecuteTemplate(&buf, name, data); err != nil {
+		middleware.SetInternalError(r, fmt.Errorf("error rendering template %s: %w", name, err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	buf.WriteTo(w)
+}
+
+// formatTime renders an optional timestamp for display.
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "unknown"
+	}
+	return t.UTC().Format("2006-01-02 15:04 MST")
 }
 
 func serveEmbeddedContent(w http.ResponseWriter, r *http.Request, contentKey ContentName) {
@@ -69,7 +134,7 @@ func serveEmbeddedContent(w http.ResponseWriter, r *http.Request, contentKey Con
 		contentType = "image/x-icon"
 	case stat.Name() == "styles.css":
 		contentType = "text/css"
-	case stat.Name() == "new.html" || stat.Name() == "index.html":
+	case stat.Name() == "index.html":
 		contentType = "text/html"
 	}
 
@@ -1,62 +1,235 @@
 package handler
 
 import (
+	"context"
+	"errors"
+	"expvar"
 	"github.com/dfryer1193/golinks/config"
 	"github.com/dfryer1193/golinks/internal/links"
+	"github.com/dfryer1193/golinks/internal/normalize"
+	"github.com/dfryer1193/golinks/internal/search"
+	"github.com/dfryer1193/golinks/internal/server"
+	"github.com/dfryer1193/golinks/models"
 	"github.com/go-chi/chi/v5"
-	"github.com/rs/zerolog/log"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync/atomic"
 )
 
+// reservedPaths are the top-level paths served by golinks itself, which can
+// therefore never be used for links.
+var reservedPaths = []string{"api", "update", "styles.css", "favicon.ico", "opensearch.xml", "healthz", "readyz"}
+
+// maxSuggestions is the most links offered when a path does not exist.
+const maxSuggestions = 5
+
 // GolinkHandler handles all incoming/outgoing http requests for go links.
 type GolinkHandler struct {
 	linkMap         *links.LinkMap
 	apiHandler      *ApiHandler
 	frontendHandler *FrontendHandler
+	internalDomains []string
+	searchOptions   search.Options
+	draining        atomic.Bool
+	redirectLimiter *server.RateLimiter
+	searchLimiter   *server.RateLimiter
+	mutationLimiter *server.RateLimiter
 }
 
-// NewGoLinkService returns a reference to a new instance of a GolinkHandler
-func NewGoLinkService(router *chi.Mux, cfg *config.Config) {
-	linkMap := links.NewLinkMap(cfg.StorageType, cfg.ConfigFile)
-	apiHandler := NewApiHandler(linkMap)
+// NewGoLinkService returns a reference to a new instance of a GolinkHandler,
+// with its routes mounted on router. If adminRouter is not nil, the routes that
+// change links, export them or report health are only mounted there, and
+// adminRouter serves every other route as well.
+func NewGoLinkService(router *chi.Mux, adminRouter *chi.Mux, cfg *config.Config) *GolinkHandler {
+	linkMap := links.NewLinkMap(
+		cfg.StorageType,
+		cfg.ConfigFile,
+		links.WithArchiveGracePeriod(cfg.ArchiveGracePeriod),
+		links.WithTrashRetention(cfg.TrashRetention),
+		links.WithPopularityHalfLife(cfg.PopularityHalfLife),
+		links.WithNormalizer(normalize.New(cfg.Normalization...)),
+		links.WithPathPolicy(links.PathPolicy{
+			Reserved:     append(slices.Clone(reservedPaths), cfg.ReservedPaths...),
+			AllowedChars: cfg.PathChars,
+			MaxLength:    cfg.MaxPathLength,
+		}),
+		links.WithTargetPolicy(cfg.TargetPolicy),
+	)
+	searchOptions := search.Options{
+		MaxDistance: cfg.SearchMaxDistance,
+		Limit:       cfg.SearchLimit,
+	}
+	apiHandler := NewApiHandler(linkMap, searchOptions)
 	frontendHandler := NewFrontendHandler()
 	service := &GolinkHandler{
 		linkMap:         linkMap,
 		apiHandler:      apiHandler,
 		frontendHandler: frontendHandler,
+		internalDomains: cfg.InternalDomains,
+		searchOptions:   searchOptions,
+		redirectLimiter: server.NewRateLimiter("redirect", cfg.RedirectRateLimit),
+		searchLimiter:   server.NewRateLimiter("search", cfg.SearchRateLimit),
+		mutationLimiter: server.NewRateLimiter("mutation", cfg.MutationRateLimit),
+	}
+
+	if adminRouter == nil {
+		service.mount(router, true)
+	} else {
+		service.mount(router, false)
+		service.mount(adminRouter, true)
 	}
 
+	return service
+}
+
+// Close stops accepting changes to links and waits for the changes already
+// made to be saved. It should be called once the servers have stopped.
+func (h *GolinkHandler) Close(ctx context.Context) error {
+	return h.linkMap.Close(ctx)
+}
+
+// mount adds the routes of the service to router, including the
+// administrative ones if admin is set. Following links, searching and changing
+// links are rate limited separately, as they cost very different amounts.
+func (h *GolinkHandler) mount(router *chi.Mux, admin bool) {
 	router.Route("/api/v1", func(r chi.Router) {
-		r.Get("/all", apiHandler.getAll)
-		r.Get("/all/alfred", apiHandler.getAllForAlfred)
-		r.Get("/search", apiHandler.search)
-		r.Get("/links/{path}", apiHandler.getLink)
-		r.Post("/links/{path}", apiHandler.postLink)
-		r.Delete("/links/{path}", apiHandler.deleteLink)
-		r.Get("/export", apiHandler.exportLinks)
-		r.Post("/import", apiHandler.importLinks)
+		r.Group(func(r chi.Router) {
+			r.Use(h.searchLimiter.Middleware)
+			r.Get("/all", h.apiHandler.getAll)
+			r.Get("/all/alfred", h.apiHandler.getAllForAlfred)
+			r.Get("/search", h.apiHandler.search)
+			r.Get("/suggest", h.apiHandler.suggest)
+		})
+		r.Get("/links/{path}", h.apiHandler.getLink)
+		if admin {
+			r.Group(func(r chi.Router) {
+				r.Use(sameOriginMiddleware)
+				r.Use(h.mutationLimiter.Middleware)
+				r.Post("/links/{path}", h.apiHandler.postLink)
+				r.Delete("/links/{path}", h.apiHandler.deleteLink)
+				r.Post("/trash/{path}/restore", h.apiHandler.restoreLink)
+				r.Post("/import", h.apiHandler.importLinks)
+			})
+			r.Get("/trash", h.apiHandler.getTrash)
+			r.Get("/export", h.apiHandler.exportLinks)
+			r.Get("/metrics", expvar.Handler().ServeHTTP)
+		}
 	})
 
 	router.Route("/", func(r chi.Router) {
 		r.Use(noCacheMiddleware)
-		r.Get("/", frontendHandler.serveHomepage)
-		r.Get("/favicon.ico", frontendHandler.serveFavicon)
-		r.Get("/styles.css", frontendHandler.serveStyles)
-		r.Get("/update", frontendHandler.serveNewForm)
-		r.Get("/{path}", service.handleGet)
+		r.Use(securityHeadersMiddleware)
+		r.Get("/", h.frontendHandler.serveHomepage)
+		r.Get("/favicon.ico", h.frontendHandler.serveFavicon)
+		r.Get("/styles.css", h.frontendHandler.serveStyles)
+		r.Get("/opensearch.xml", h.frontendHandler.serveOpenSearch)
+		if admin {
+			r.Get("/healthz", h.serveHealth)
+			r.Get("/readyz", h.serveReady)
+		}
+		r.Get("/update", h.frontendHandler.serveNewForm)
+		r.With(h.redirectLimiter.Middleware).Get("/{path}", h.handleGet)
 	})
 }
 
 func (h *GolinkHandler) handleGet(w http.ResponseWriter, r *http.Request) {
 	path := chi.URLParam(r, "path")
 
-	target, exists := h.linkMap.Get(path)
+	if infoPath, isInfo := strings.CutSuffix(path, "+"); isInfo {
+		h.handleInfo(w, r, infoPath)
+		return
+	}
+
+	canonical, err := h.linkMap.Resolve(r.Context(), path)
+
+	if err == nil {
+		h.linkMap.RecordClick(canonical.Path)
+		server.SetLink(r, canonical.Path, canonical.Target)
+		trace.SpanFromContext(r.Context()).SetAttributes(
+			attribute.String("golinks.link", canonical.Path),
+			attribute.String("golinks.target", canonical.Target),
+		)
+		mode := h.redirectMode(canonical)
+		zerolog.Ctx(r.Context()).Debug().
+			Str("target", canonical.Target).
+			Str("canonical", canonical.Path).
+			Str("mode", string(mode)).
+			Msg("Shortcut found! Redirecting...")
+		if mode == models.RedirectInterstitial {
+			h.frontendHandler.serveInterstitial(w, r, path, canonical.Target)
+			return
+		}
+		if mode == models.RedirectMoved || mode == models.RedirectPermanent {
+			// Permanent redirects are meant to be remembered by browsers.
+			allowCaching(w)
+		}
+		http.Redirect(w, r, canonical.Target, redirectStatus(mode))
+		return
+	}
+
+	if !errors.Is(err, links.ErrNotFound) {
+		zerolog.Ctx(r.Context()).Warn().Err(err).Str("path", path).Msg("Failed to resolve alias")
+	}
+
+	h.frontendHandler.serveNotFound(w, r, path, h.suggest(r.Context(), path))
+}
+
+// suggest returns active links that the visitor of a missing path may have
+// meant, best match first.
+func (h *GolinkHandler) suggest(ctx context.Context, path string) []*models.Entry {
+	if h.linkMap.Normalize(path) == "" {
+		return nil
+	}
+
+	opts := h.searchOptions
+	opts.Limit = maxSuggestions
+	return h.linkMap.SearchActive(ctx, path, opts)
+}
 
-	if exists {
-		log.Debug().Str("target", target).Msg("Shortcut found! Redirecting...")
-		http.Redirect(w, r, target, http.StatusTemporaryRedirect)
+// handleInfo shows the details of a link instead of following it, as is the
+// convention for go links with a trailing '+'.
+func (h *GolinkHandler) handleInfo(w http.ResponseWriter, r *http.Request, path string) {
+	entry, exists := h.linkMap.GetEntry(path)
+	if !exists {
+		http.Redirect(w, r, "/update?path="+url.QueryEscape(path), http.StatusFound)
 		return
 	}
 
-	h.frontendHandler.serveNewForm(w, r)
+	h.frontendHandler.serveInfo(w, r, entry)
+}
+
+// redirectMode returns how to send visitors to the entry's target. Links
+// without an explicit mode use an interstitial for targets outside the
+// internal domains, if any are configured, and a temporary redirect otherwise.
+func (h *GolinkHandler) redirectMode(entry *models.Entry) models.RedirectMode {
+	if entry.Redirect != models.RedirectDefault {
+		return entry.Redirect
+	}
+
+	if len(h.internalDomains) > 0 {
+		target, err := url.Parse(entry.Target)
+		if err != nil || !links.MatchesDomain(strings.ToLower(target.Hostname()), h.internalDomains) {
+			return models.RedirectInterstitial
+		}
+	}
+
+	return models.RedirectTemporary
+}
+
+func redirectStatus(mode models.RedirectMode) int {
+	switch mode {
+	case models.RedirectMoved:
+		return http.StatusMovedPermanently
+	case models.RedirectFound:
+		return http.StatusFound
+	case models.RedirectPermanent:
+		return http.StatusPermanentRedirect
+	default:
+		return http.StatusTemporaryRedirect
+	}
 }
@@ -0,0 +1,224 @@
+package handler
+
+import (
+	"context"
+	"github.com/dfryer1193/golinks/internal/links"
+	"github.com/dfryer1193/golinks/internal/links/storage"
+	"github.com/dfryer1193/golinks/internal/search"
+	"github.com/dfryer1193/golinks/models"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGolinkHandler_Suggest(t *testing.T) {
+	linkMap := links.NewLinkMap(storage.NONE, "")
+	expired := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	linkMap.Put(context.Background(), &models.Entry{Path: "dashboard", Target: "https://dash.com"})
+	linkMap.Put(context.Background(), &models.Entry{Path: "dashboard-old", Target: "https://old.com"})
+	linkMap.Put(context.Background(), &models.Entry{Path: "dash-retired", Target: "https://retired.com", ExpiresAt: &expired})
+	linkMap.Put(context.Background(), &models.Entry{Path: "engineering-wiki", Target: "https://wiki.com"})
+	h := &GolinkHandler{linkMap: linkMap, searchOptions: search.DefaultOptions()}
+
+	tests := []struct {
+		name string
+		path string
+		want []string
+	}{
+		{name: "Suggests a misspelled link", path: "dahsboard", want: []string{"dashboard", "dashboard-old"}},
+		{name: "Suggests prefix matches shortest first", path: "Dash", want: []string{"dashboard", "dashboard-old"}},
+		{name: "Suggests nothing for unrelated paths", path: "kubernetes", want: []string{}},
+		{name: "Suggests nothing for a path that normalizes away", path: "--", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			suggestions := h.suggest(context.Background(), tt.path)
+			var got []string
+			if suggestions != nil {
+				got = make([]string, len(suggestions))
+				for i, entry := range suggestions {
+					got[i] = entry.Path
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("suggest(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGolinkHandler_MountAdminSeparately(t *testing.T) {
+	linkMap := links.NewLinkMap(storage.NONE, "")
+	linkMap.Put(context.Background(), &models.Entry{Path: "wiki", Target: "https://wiki.com"})
+	h := &GolinkHandler{
+		linkMap:         linkMap,
+		apiHandler:      NewApiHandler(linkMap, search.DefaultOptions()),
+		frontendHandler: NewFrontendHandler(),
+		searchOptions:   search.DefaultOptions(),
+	}
+	public := chi.NewRouter()
+	admin := chi.NewRouter()
+	h.mount(public, false)
+	h.mount(admin, true)
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		public int
+		admin  int
+	}{
+		{name: "Follow a link", method: http.MethodGet, target: "/wiki", public: http.StatusTemporaryRedirect, admin: http.StatusTemporaryRedirect},
+		{name: "Read a link", method: http.MethodGet, target: "/api/v1/links/wiki", public: http.StatusOK, admin: http.StatusOK},
+		{name: "Search", method: http.MethodGet, target: "/api/v1/search?query=wiki", public: http.StatusOK, admin: http.StatusOK},
+		{name: "Export", method: http.MethodGet, target: "/api/v1/export", public: http.StatusNotFound, admin: http.StatusOK},
+		{name: "Trash", method: http.MethodGet, target: "/api/v1/trash", public: http.StatusNotFound, admin: http.StatusOK},
+		{name: "Delete a link", method: http.MethodDelete, target: "/api/v1/links/other", public: http.StatusMethodNotAllowed, admin: http.StatusNoContent},
+		{name: "Readiness", method: http.MethodGet, target: "/readyz", public: http.StatusNotFound, admin: http.StatusOK},
+		{name: "Metrics", method: http.MethodGet, target: "/api/v1/metrics", public: http.StatusNotFound, admin: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, router := range []struct {
+				name string
+				mux  *chi.Mux
+				want int
+			}{{"public", public, tt.public}, {"admin", admin, tt.admin}} {
+				rec := httptest.NewRecorder()
+				router.mux.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.target, nil))
+				if rec.Code != router.want {
+					t.Errorf("%s %s on the %s router: status = %d, want %d", tt.method, tt.target, router.name, rec.Code, router.want)
+				}
+			}
+		})
+	}
+}
+
+func TestGolinkHandler_RedirectModes(t *testing.T) {
+	linkMap := links.NewLinkMap(storage.NONE, "")
+	for _, entry := range []*models.Entry{
+		{Path: "default", Target: "https://default.example.com"},
+		{Path: "moved", Target: "https://moved.example.com", Redirect: models.RedirectMoved},
+		{Path: "found", Target: "https://found.example.com", Redirect: models.RedirectFound},
+		{Path: "temporary", Target: "https://temporary.example.com", Redirect: models.RedirectTemporary},
+		{Path: "permanent", Target: "https://permanent.example.com", Redirect: models.RedirectPermanent},
+		{Path: "warn", Target: "https://warn.example.com/page", Redirect: models.RedirectInterstitial},
+		{Path: "wiki", Target: "https://wiki.corp.com"},
+		{Path: "partner", Target: "https://partner.com"},
+	} {
+		if err := linkMap.Put(context.Background(), entry); err != nil {
+			t.Fatalf("Put(%s): %v", entry.Path, err)
+		}
+	}
+
+	tests := []struct {
+		name            string
+		internalDomains []string
+		path            string
+		status          int
+		location        string
+		cacheable       bool
+	}{
+		{name: "Defaults to a temporary redirect", path: "default", status: http.StatusTemporaryRedirect, location: "https://default.example.com"},
+		{name: "Redirects with 301", path: "moved", status: http.StatusMovedPermanently, location: "https://moved.example.com", cacheable: true},
+		{name: "Redirects with 302", path: "found", status: http.StatusFound, location: "https://found.example.com"},
+		{name: "Redirects with 307", path: "temporary", status: http.StatusTemporaryRedirect, location: "https://temporary.example.com"},
+		{name: "Redirects with 308", path: "permanent", status: http.StatusPermanentRedirect, location: "https://permanent.example.com", cacheable: true},
+		{name: "Shows an interstitial", path: "warn", status: http.StatusOK},
+		{name: "Redirects to internal domains by default", internalDomains: []string{"corp.com"}, path: "wiki", status: http.StatusTemporaryRedirect, location: "https://wiki.corp.com"},
+		{name: "Shows an interstitial for other domains by default", internalDomains: []string{"corp.com"}, path: "partner", status: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &GolinkHandler{
+				linkMap:         linkMap,
+				frontendHandler: NewFrontendHandler(),
+				internalDomains: tt.internalDomains,
+				searchOptions:   search.DefaultOptions(),
+			}
+			router := chi.NewRouter()
+			router.With(noCacheMiddleware).Get("/{path}", h.handleGet)
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/"+tt.path, nil))
+			if rec.Code != tt.status {
+				t.Fatalf("GET /%s status = %d, want %d", tt.path, rec.Code, tt.status)
+			}
+			if got := rec.Header().Get("Location"); got != tt.location {
+				t.Errorf("GET /%s Location = %q, want %q", tt.path, got, tt.location)
+			}
+			for _, name := range []string{"Cache-Control", "Pragma", "Expires"} {
+				if got := rec.Header().Get(name); (got == "") != tt.cacheable {
+					t.Errorf("GET /%s %s = %q, want it set only for uncacheable responses", tt.path, name, got)
+				}
+			}
+			if tt.location == "" {
+				entry, _ := linkMap.GetEntry(tt.path)
+				body := rec.Body.String()
+				if !strings.Contains(body, `href="`+entry.Target+`"`) || !strings.Contains(body, "go/"+tt.path+" is taking you to") {
+					t.Errorf("GET /%s should show the interstitial for %s, got %s", tt.path, entry.Target, body)
+				}
+			}
+		})
+	}
+}
+
+func TestGolinkHandler_Info(t *testing.T) {
+	linkMap := links.NewLinkMap(storage.NONE, "")
+	linkMap.Put(context.Background(), &models.Entry{Path: "kubernetes", Target: "https://k8s.io", Owner: "platform"})
+	linkMap.Put(context.Background(), &models.Entry{Path: "k8s", AliasOf: "kubernetes"})
+	h := &GolinkHandler{linkMap: linkMap, frontendHandler: NewFrontendHandler(), searchOptions: search.DefaultOptions()}
+	router := chi.NewRouter()
+	router.Get("/{path}", h.handleGet)
+
+	tests := []struct {
+		name     string
+		path     string
+		status   int
+		location string
+		contains []string
+	}{
+		{
+			name:     "Shows the details of a link",
+			path:     "kubernetes+",
+			status:   http.StatusOK,
+			contains: []string{"<title>go/kubernetes</title>", `href="https://k8s.io"`, "platform"},
+		},
+		{
+			name:     "Shows an alias with its canonical target",
+			path:     "k8s+",
+			status:   http.StatusOK,
+			contains: []string{"<title>go/k8s</title>", `href="/kubernetes+"`, `href="https://k8s.io"`},
+		},
+		{
+			name:     "Offers to create a missing link",
+			path:     "missing+",
+			status:   http.StatusFound,
+			location: "/update?path=missing",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/"+tt.path, nil))
+			if rec.Code != tt.status {
+				t.Fatalf("GET /%s status = %d, want %d", tt.path, rec.Code, tt.status)
+			}
+			if got := rec.Header().Get("Location"); got != tt.location {
+				t.Errorf("GET /%s Location = %q, want %q", tt.path, got, tt.location)
+			}
+			for _, want := range tt.contains {
+				if !strings.Contains(rec.Body.String(), want) {
+					t.Errorf("GET /%s should contain %q", tt.path, want)
+				}
+			}
+			if clicks := linkMap.GetAll()["kubernetes"].Clicks; clicks != 0 {
+				t.Errorf("viewing details should not count as a click, got %d", clicks)
+			}
+		})
+	}
+}
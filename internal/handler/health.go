@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"errors"
+	"github.com/dfryer1193/mjolnir/utils"
+	"net/http"
+)
+
+// errDraining is reported by /readyz once the server has begun shutting down.
+var errDraining = errors.New("shutting down")
+
+type healthResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Drain makes /readyz fail, so that load balancers stop sending requests
+// before the server stops accepting them.
+func (h *GolinkHandler) Drain() {
+	h.draining.Store(true)
+}
+
+// serveHealth reports that the process is alive and serving requests.
+func (h *GolinkHandler) serveHealth(w http.ResponseWriter, r *http.Request) {
+	utils.RespondJSON(w, r, http.StatusOK, healthResponse{Status: "ok"})
+}
+
+// serveReady reports whether the server should receive traffic: links are
+// loaded and kept up to date from storage, and the server is not shutting
+// down.
+func (h *GolinkHandler) serveReady(w http.ResponseWriter, r *http.Request) {
+	if err := h.ready(); err != nil {
+		utils.RespondJSON(w, r, http.StatusServiceUnavailable, healthResponse{
+			Status: "unavailable",
+			Error:  err.Error(),
+		})
+		return
+	}
+	utils.RespondJSON(w, r, http.StatusOK, healthResponse{Status: "ok"})
+}
+
+func (h *GolinkHandler) ready() error {
+	if h.draining.Load() {
+		return errDraining
+	}
+	return h.linkMap.Check()
+}
@@ -0,0 +1,33 @@
+package handler
+
+import (
+	"github.com/dfryer1193/golinks/internal/links"
+	"github.com/dfryer1193/golinks/internal/links/storage"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGolinkHandler_Health(t *testing.T) {
+	h := &GolinkHandler{linkMap: links.NewLinkMap(storage.NONE, "")}
+	status := func(serve http.HandlerFunc) int {
+		rec := httptest.NewRecorder()
+		serve(rec, httptest.NewRequest(http.MethodGet, "http://go/", nil))
+		return rec.Code
+	}
+
+	if got := status(h.serveHealth); got != http.StatusOK {
+		t.Errorf("/healthz status = %d, want %d", got, http.StatusOK)
+	}
+	if got := status(h.serveReady); got != http.StatusOK {
+		t.Errorf("/readyz status = %d, want %d", got, http.StatusOK)
+	}
+
+	h.Drain()
+	if got := status(h.serveReady); got != http.StatusServiceUnavailable {
+		t.Errorf("/readyz status while draining = %d, want %d", got, http.StatusServiceUnavailable)
+	}
+	if got := status(h.serveHealth); got != http.StatusOK {
+		t.Errorf("/healthz status while draining = %d, want %d", got, http.StatusOK)
+	}
+}
@@ -10,3 +10,12 @@ func noCacheMiddleware(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// allowCaching drops the headers set by noCacheMiddleware, leaving it to the
+// client how long to cache the response.
+func allowCaching(w http.ResponseWriter) {
+	header := w.Header()
+	header.Del("Cache-Control")
+	header.Del("Pragma")
+	header.Del("Expires")
+}
@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"github.com/dfryer1193/mjolnir/middleware"
+	"net/http"
+	"net/url"
+)
+
+// maxCompletions is the most links offered to a browser as completions.
+const maxCompletions = 8
+
+const (
+	openSearchContentType  = "application/opensearchdescription+xml"
+	suggestionsContentType = "application/x-suggestions+json"
+)
+
+// openSearchDescription is an OpenSearch 1.1 description document, which lets
+// browsers add golinks as a search engine.
+type openSearchDescription struct {
+	XMLName       xml.Name        `xml:"http://a9.com/-/spec/opensearch/1.1/ OpenSearchDescription"`
+	ShortName     string          `xml:"ShortName"`
+	Description   string          `xml:"Description"`
+	InputEncoding string          `xml:"InputEncoding"`
+	Image         openSearchImage `xml:"Image"`
+	Urls          []openSearchUrl `xml:"Url"`
+}
+
+type openSearchImage struct {
+	Width  int    `xml:"width,attr"`
+	Height int    `xml:"height,attr"`
+	Type   string `xml:"type,attr"`
+	URL    string `xml:",chardata"`
+}
+
+type openSearchUrl struct {
+	Type     string `xml:"type,attr"`
+	Method   string `xml:"method,attr"`
+	Template string `xml:"template,attr"`
+}
+
+// serveOpenSearch serves the OpenSearch description document. Searching
+// follows the link named by the search terms, which lists close matches if
+// there is none, and completions come from the suggestions endpoint.
+func (h *FrontendHandler) serveOpenSearch(w http.ResponseWriter, r *http.Request) {
+	base := baseURL(r)
+	description := openSearchDescription{
+		ShortName:     "Go/Links",
+		Description:   "Go links on " + r.Host,
+		InputEncoding: "UTF-8",
+		Image: openSearchImage{
+			Width:  16,
+			Height: 16,
+			Type:   "image/x-icon",
+			URL:    base + "/favicon.ico",
+		},
+		Urls: []openSearchUrl{
+			{Type: "text/html", Method: "get", Template: base + "/{searchTerms}"},
+			{Type: suggestionsContentType, Method: "get", Template: base + "/api/v1/suggest?q={searchTerms}"},
+		},
+	}
+
+	body, err := xml.MarshalIndent(description, "", "  ")
+	if err != nil {
+		middleware.SetInternalError(r, fmt.Errorf("error encoding OpenSearch description: %w", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", openSearchContentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(xml.Header))
+	w.Write(body)
+}
+
+// suggest answers browser completion requests in the OpenSearch suggestions
+// format: the query, followed by the completions, their descriptions and the
+// URLs they lead to.
+func (h *ApiHandler) suggest(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("q")
+
+	opts := h.searchOptions
+	opts.Limit = maxCompletions
+	results := h.linkMap.SearchActive(r.Context(), query, opts)
+
+	base := baseURL(r)
+	completions := make([]string, len(results))
+	descriptions := make([]string, len(results))
+	urls := make([]string, len(results))
+	for i, entry := range results {
+		completions[i] = entry.Path
+		descriptions[i] = entry.Description
+		if descriptions[i] == "" {
+			descriptions[i] = entry.Target
+		}
+		urls[i] = base + "/" + url.PathEscape(entry.Path)
+	}
+
+	w.Header().Set("Content-Type", suggestionsContentType)
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode([]any{query, completions, descriptions, urls})
+}
+
+// baseURL returns the scheme and host the request was made to, as seen by the
+// client. It relies on the RealIP middleware having removed X-Forwarded-Proto
+// from requests that did not come through a trusted proxy.
+func baseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+		scheme = proto
+	}
+	return scheme + "://" + r.Host
+}
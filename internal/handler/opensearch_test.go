@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"github.com/dfryer1193/golinks/internal/links"
+	"github.com/dfryer1193/golinks/internal/links/storage"
+	"github.com/dfryer1193/golinks/internal/search"
+	"github.com/dfryer1193/golinks/models"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestApiHandler_Suggest(t *testing.T) {
+	linkMap := links.NewLinkMap(storage.NONE, "")
+	linkMap.Put(context.Background(), &models.Entry{Path: "kubernetes", Target: "https://k8s.example.com", Description: "Cluster dashboard"})
+	linkMap.Put(context.Background(), &models.Entry{Path: "kube-docs", Target: "https://docs.example.com/kube"})
+	linkMap.Put(context.Background(), &models.Entry{Path: "wiki", Target: "https://wiki.example.com"})
+	h := NewApiHandler(linkMap, search.DefaultOptions())
+
+	req := httptest.NewRequest(http.MethodGet, "http://go/api/v1/suggest?q=kube", nil)
+	rec := httptest.NewRecorder()
+	h.suggest(rec, req)
+
+	if got := rec.Header().Get("Content-Type"); got != suggestionsContentType {
+		t.Errorf("Content-Type = %q, want %q", got, suggestionsContentType)
+	}
+	var got []any
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	want := []any{
+		"kube",
+		[]any{"kube-docs", "kubernetes"},
+		[]any{"https://docs.example.com/kube", "Cluster dashboard"},
+		[]any{"http://go/kube-docs", "http://go/kubernetes"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestFrontendHandler_ServeOpenSearch(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "http://go/opensearch.xml", nil)
+	req.Header.Set("X-Forwarded-Proto", "https")
+	rec := httptest.NewRecorder()
+	NewFrontendHandler().serveOpenSearch(rec, req)
+
+	if got := rec.Header().Get("Content-Type"); got != openSearchContentType {
+		t.Errorf("Content-Type = %q, want %q", got, openSearchContentType)
+	}
+	var description openSearchDescription
+	if err := xml.Unmarshal(rec.Body.Bytes(), &description); err != nil {
+		t.Fatalf("invalid XML: %v", err)
+	}
+	want := []openSearchUrl{
+		{Type: "text/html", Method: "get", Template: "https://go/{searchTerms}"},
+		{Type: suggestionsContentType, Method: "get", Template: "https://go/api/v1/suggest?q={searchTerms}"},
+	}
+	if !reflect.DeepEqual(description.Urls, want) {
+		t.Errorf("Urls = %v, want %v", description.Urls, want)
+	}
+}
@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/dfryer1193/mjolnir/middleware"
+)
+
+var errCrossOrigin = errors.New("cross-origin requests cannot change links")
+
+// sameOriginMiddleware rejects changes that a browser requested on behalf of
+// another site, so that visiting a page elsewhere cannot rewrite links through
+// a form or a script. Requests without the headers browsers add, such as those
+// made by scripts and the command line, are let through.
+func sameOriginMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			if !isSameOrigin(r) {
+				middleware.SetError(r, http.StatusForbidden, errCrossOrigin)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isSameOrigin reports whether a request came from a golinks page, or from
+// something other than a browser. Sec-Fetch-Site is trusted where browsers send
+// it, and older ones are checked by their Origin header instead.
+func isSameOrigin(r *http.Request) bool {
+	switch r.Header.Get("Sec-Fetch-Site") {
+	case "same-origin", "none":
+		return true
+	case "":
+	default:
+		return false
+	}
+
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	parsed, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return parsed.Host != "" && strings.EqualFold(parsed.Host, r.Host)
+}
@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"github.com/dfryer1193/mjolnir/middleware"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSameOriginMiddleware(t *testing.T) {
+	tests := []struct {
+		name    string
+		method  string
+		headers map[string]string
+		want    int
+	}{
+		{name: "Same-origin fetch", method: http.MethodPost, headers: map[string]string{"Sec-Fetch-Site": "same-origin", "Origin": "http://go"}, want: http.StatusNoContent},
+		{name: "Cross-site form", method: http.MethodPost, headers: map[string]string{"Sec-Fetch-Site": "cross-site", "Origin": "https://evil.example"}, want: http.StatusForbidden},
+		{name: "Same-site subdomain", method: http.MethodDelete, headers: map[string]string{"Sec-Fetch-Site": "same-site"}, want: http.StatusForbidden},
+		{name: "Older browser, same origin", method: http.MethodPost, headers: map[string]string{"Origin": "http://go"}, want: http.StatusNoContent},
+		{name: "Older browser, other origin", method: http.MethodPost, headers: map[string]string{"Origin": "https://evil.example"}, want: http.StatusForbidden},
+		{name: "Sandboxed frame", method: http.MethodPost, headers: map[string]string{"Origin": "null"}, want: http.StatusForbidden},
+		{name: "Command line", method: http.MethodPost, want: http.StatusNoContent},
+		{name: "Cross-site read", method: http.MethodGet, headers: map[string]string{"Sec-Fetch-Site": "cross-site"}, want: http.StatusNoContent},
+	}
+	handler := middleware.ErrorHandler(sameOriginMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "http://go/api/v1/import", nil)
+			for key, value := range tt.headers {
+				req.Header.Set(key, value)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
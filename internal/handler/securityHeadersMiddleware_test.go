@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"github.com/dfryer1193/golinks/internal/links"
+	"github.com/dfryer1193/golinks/internal/links/storage"
+	"github.com/dfryer1193/golinks/internal/search"
+	"github.com/dfryer1193/golinks/models"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSecurityHeadersMiddleware(t *testing.T) {
+	linkMap := links.NewLinkMap(storage.NONE, "")
+	linkMap.Put(context.Background(), &models.Entry{Path: "wiki", Target: "https://wiki.com"})
+	h := &GolinkHandler{
+		linkMap:         linkMap,
+		apiHandler:      NewApiHandler(linkMap, search.DefaultOptions()),
+		frontendHandler: NewFrontendHandler(),
+		searchOptions:   search.DefaultOptions(),
+	}
+	router := chi.NewRouter()
+	h.mount(router, true)
+
+	for _, target := range []string{"/", "/update", "/wiki+", "/missing"} {
+		t.Run(target, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+
+			if got := rec.Header().Get("X-Frame-Options"); got != "DENY" {
+				t.Errorf("X-Frame-Options = %q", got)
+			}
+			if got := rec.Header().Get("Referrer-Policy"); got != "same-origin" {
+				t.Errorf("Referrer-Policy = %q", got)
+			}
+
+			// Every script on the page must be allowed, or the page breaks.
+			policy := rec.Header().Get("Content-Security-Policy")
+			scripts := inlineScript.FindAllStringSubmatch(rec.Body.String(), -1)
+			if len(scripts) == 0 {
+				t.Fatal("page has no scripts")
+			}
+			for _, script := range scripts {
+				sum := sha256.Sum256([]byte(script[1]))
+				if hash := "'sha256-" + base64.StdEncoding.EncodeToString(sum[:]) + "'"; !strings.Contains(policy, hash) {
+					t.Errorf("policy %q does not allow script %s", policy, hash)
+				}
+			}
+		})
+	}
+}
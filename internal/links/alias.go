@@ -0,0 +1,138 @@
+package links
+
+import (
+	"context"
+	"errors"
+	"github.com/dfryer1193/golinks/models"
+	"slices"
+	"time"
+)
+
+// maxAliasDepth is the longest chain of aliases that will be followed before
+// giving up on resolving a link.
+const maxAliasDepth = 8
+
+var (
+	// ErrAliasCycle is returned when an alias chain leads back to itself.
+	ErrAliasCycle = errors.New("alias chain forms a cycle")
+	// ErrAliasTooDeep is returned when an alias chain is longer than
+	// maxAliasDepth.
+	ErrAliasTooDeep = errors.New("alias chain is too deep")
+	// ErrAliasTargetMissing is returned when an alias points at a link that
+	// does not exist.
+	ErrAliasTargetMissing = errors.New("alias points at a link that does not exist")
+	// ErrAliasHasTarget is returned when an entry sets both a target and an
+	// alias.
+	ErrAliasHasTarget = errors.New("an alias cannot also have its own target")
+	// ErrHasAliases is returned when deleting a link that aliases still point
+	// at.
+	ErrHasAliases = errors.New("link still has aliases")
+)
+
+// Resolve follows the alias chain starting at key and returns the canonical
+// entry it ends at. Every link along the chain must currently be active.
+func (l *LinkMap) Resolve(ctx context.Context, key string) (_ *models.Entry, err error) {
+	_, span := startSpan(ctx, "Resolve", key)
+	defer func() {
+		if !errors.Is(err, ErrNotFound) {
+			endSpan(span, err)
+			return
+		}
+		span.End()
+	}()
+
+	l.mapLock.RLock()
+	defer l.mapLock.RUnlock()
+
+	entry, exists := l.m[l.key(key)]
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	now := l.now()
+	canonical, err := l.resolveLocked(entry, nil, now, true)
+	if err != nil {
+		return nil, err
+	}
+	return l.withState(canonical), nil
+}
+
+// resolveLocked walks the alias chain from entry through the link map. If
+// pending is non-nil it stands in for the map entry with the same path, which
+// lets callers check a proposed change before applying it. If requireActive is
+// set, inactive links along the chain are treated as missing. Callers must
+// hold mapLock.
+func (l *LinkMap) resolveLocked(entry *models.Entry, pending *models.Entry, now time.Time, requireActive bool) (*models.Entry, error) {
+	return l.resolveIn(l.m, entry, pending, now, requireActive)
+}
+
+// resolveIn is resolveLocked over the links in m, keyed by normalized path.
+func (l *LinkMap) resolveIn(m map[string]*models.Entry, entry *models.Entry, pending *models.Entry, now time.Time, requireActive bool) (*models.Entry, error) {
+	visited := map[string]bool{}
+	current := entry
+	for depth := 0; ; depth++ {
+		if requireActive && current.StateAt(now) != models.StateActive {
+			return nil, ErrNotFound
+		}
+		if current.AliasOf == "" {
+			return current, nil
+		}
+		if depth >= maxAliasDepth {
+			return nil, ErrAliasTooDeep
+		}
+
+		visited[l.key(current.Path)] = true
+		nextKey := l.key(current.AliasOf)
+		if visited[nextKey] {
+			return nil, ErrAliasCycle
+		}
+
+		next, exists := m[nextKey]
+		if pending != nil && nextKey == l.key(pending.Path) {
+			next, exists = pending, true
+		}
+		if !exists {
+			return nil, ErrAliasTargetMissing
+		}
+		current = next
+	}
+}
+
+// validateAlias checks that storing entry would not create a dangling, cyclic
+// or overly deep alias chain, including chains that pass through entry from
+// other aliases. Problems are reported as a ValidationError wrapping one of
+// the ErrAlias errors. Callers must hold mapLock.
+func (l *LinkMap) validateAlias(entry *models.Entry) error {
+	now := l.now()
+	if _, err := l.resolveLocked(entry, entry, now, false); err != nil {
+		return aliasError(err)
+	}
+
+	for key, other := range l.m {
+		if other.AliasOf == "" || key == l.key(entry.Path) {
+			continue
+		}
+		if _, err := l.resolveLocked(other, entry, now, false); errors.Is(err, ErrAliasCycle) || errors.Is(err, ErrAliasTooDeep) {
+			return aliasError(err)
+		}
+	}
+	return nil
+}
+
+// aliasError reports a problem with an alias chain against the aliasOf field.
+func aliasError(err error) error {
+	return &ValidationError{Field: "aliasOf", Reason: ReasonInvalidAlias, Message: err.Error(), Err: err}
+}
+
+// aliasesOf returns the paths of the aliases that point directly at key, in
+// order. Callers must hold mapLock.
+func (l *LinkMap) aliasesOf(key string) []string {
+	var aliases []string
+	for _, entry := range l.m {
+		if entry.AliasOf != "" && l.key(entry.AliasOf) == key {
+			aliases = append(aliases, entry.Path)
+		}
+	}
+	slices.Sort(aliases)
+	return aliases
+}
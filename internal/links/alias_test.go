@@ -0,0 +1,183 @@
+package links
+
+import (
+	"context"
+	"errors"
+	"github.com/dfryer1193/golinks/internal/links/storage"
+	"github.com/dfryer1193/golinks/models"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLinkMap_AliasFollowsCanonical(t *testing.T) {
+	links := NewLinkMap(storage.NONE, "")
+	links.Put(context.Background(), &models.Entry{Path: "kubernetes", Target: "https://k8s.io"})
+	links.Put(context.Background(), &models.Entry{Path: "k8s", AliasOf: "kubernetes"})
+	links.Put(context.Background(), &models.Entry{Path: "kube", AliasOf: "k8s"})
+
+	for _, key := range []string{"kubernetes", "k8s", "kube"} {
+		if target, _ := links.Get(key); target != "https://k8s.io" {
+			t.Errorf("Get(%s) = %s, want https://k8s.io", key, target)
+		}
+	}
+
+	links.Update(context.Background(), &models.Entry{Path: "kubernetes", Target: "https://kubernetes.io"})
+
+	for _, key := range []string{"kubernetes", "k8s", "kube"} {
+		if target, _ := links.Get(key); target != "https://kubernetes.io" {
+			t.Errorf("after update Get(%s) = %s, want https://kubernetes.io", key, target)
+		}
+	}
+
+	entry, _ := links.GetEntry("kube")
+	if entry.AliasOf != "k8s" || entry.Target != "https://kubernetes.io" {
+		t.Errorf("GetEntry(kube) = %+v, want alias of k8s with canonical target", entry)
+	}
+}
+
+func TestLinkMap_AliasValidation(t *testing.T) {
+	links := NewLinkMap(storage.NONE, "")
+	links.Put(context.Background(), &models.Entry{Path: "a", Target: "https://a.com"})
+	links.Put(context.Background(), &models.Entry{Path: "b", AliasOf: "a"})
+	links.Put(context.Background(), &models.Entry{Path: "c", AliasOf: "b"})
+	tests := []struct {
+		name    string
+		entry   *models.Entry
+		wantErr error
+	}{
+		{name: "Rejects self alias", entry: &models.Entry{Path: "d", AliasOf: "d"}, wantErr: ErrAliasCycle},
+		{name: "Rejects turning the canonical link into a cycle", entry: &models.Entry{Path: "a", AliasOf: "c"}, wantErr: ErrAliasCycle},
+		{name: "Rejects alias of a missing link", entry: &models.Entry{Path: "d", AliasOf: "missing"}, wantErr: ErrAliasTargetMissing},
+		{name: "Rejects alias with its own target", entry: &models.Entry{Path: "d", Target: "https://d.com", AliasOf: "a"}, wantErr: ErrAliasHasTarget},
+		{name: "Accepts alias of an alias", entry: &models.Entry{Path: "d", AliasOf: "c"}, wantErr: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := links.Put(context.Background(), tt.entry)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Put(%+v) error = %v, want %v", tt.entry, err, tt.wantErr)
+			}
+		})
+	}
+
+	if target, _ := links.Get("a"); target != "https://a.com" {
+		t.Errorf("rejected update should leave the canonical link untouched, got %s", target)
+	}
+}
+
+func TestLinkMap_ResolveDepthLimit(t *testing.T) {
+	links := NewLinkMap(storage.NONE, "")
+	links.Put(context.Background(), &models.Entry{Path: "link0", Target: "https://deep.com"})
+	for i := 1; i <= maxAliasDepth; i++ {
+		err := links.Put(context.Background(), &models.Entry{Path: aliasName(i), AliasOf: aliasName(i - 1)})
+		if err != nil {
+			t.Fatalf("Put(%s) error = %v", aliasName(i), err)
+		}
+	}
+
+	if target, _ := links.Get(aliasName(maxAliasDepth)); target != "https://deep.com" {
+		t.Errorf("chain of %d aliases should resolve, got %q", maxAliasDepth, target)
+	}
+
+	err := links.Put(context.Background(), &models.Entry{Path: "tooDeep", AliasOf: aliasName(maxAliasDepth)})
+	if !errors.Is(err, ErrAliasTooDeep) {
+		t.Errorf("expected ErrAliasTooDeep, got %v", err)
+	}
+}
+
+func TestLinkMap_ResolveRespectsCanonicalSchedule(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	past := now.Add(-time.Hour)
+
+	links := NewLinkMap(storage.NONE, "")
+	links.now = func() time.Time { return now }
+	links.Put(context.Background(), &models.Entry{Path: "offsite", Target: "https://offsite.com", ExpiresAt: &past})
+	links.Put(context.Background(), &models.Entry{Path: "event", AliasOf: "offsite"})
+
+	if _, err := links.Resolve(context.Background(), "event"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("alias of an expired link should not resolve, got %v", err)
+	}
+}
+
+func aliasName(i int) string {
+	return "link" + strconv.Itoa(i)
+}
+
+func TestLinkMap_DeleteRefusesLinksWithAliases(t *testing.T) {
+	links := NewLinkMap(storage.NONE, "")
+	links.Put(context.Background(), &models.Entry{Path: "kubernetes", Target: "https://k8s.io"})
+	links.Put(context.Background(), &models.Entry{Path: "kube", AliasOf: "kubernetes"})
+	links.Put(context.Background(), &models.Entry{Path: "k8s", AliasOf: "kubernetes"})
+
+	err := links.Delete(context.Background(), "kubernetes")
+	if !errors.Is(err, ErrHasAliases) {
+		t.Fatalf("Delete error = %v, want ErrHasAliases", err)
+	}
+	if want := "link still has aliases: k8s, kube"; err.Error() != want {
+		t.Errorf("Delete error = %q, want %q", err, want)
+	}
+	if _, exists := links.Get("kubernetes"); !exists {
+		t.Error("a refused delete should leave the link in place")
+	}
+
+	links.Delete(context.Background(), "kube")
+	links.Delete(context.Background(), "k8s")
+	if err := links.Delete(context.Background(), "kubernetes"); err != nil {
+		t.Errorf("Delete after removing its aliases error = %v", err)
+	}
+}
+
+func TestLinkMap_RestoreValidatesAlias(t *testing.T) {
+	links := NewLinkMap(storage.NONE, "")
+	links.Put(context.Background(), &models.Entry{Path: "kubernetes", Target: "https://k8s.io"})
+	links.Put(context.Background(), &models.Entry{Path: "k8s", AliasOf: "kubernetes"})
+	links.Delete(context.Background(), "k8s")
+	links.Delete(context.Background(), "kubernetes")
+
+	if _, err := links.Restore(context.Background(), "k8s"); !errors.Is(err, ErrAliasTargetMissing) {
+		t.Fatalf("Restore error = %v, want ErrAliasTargetMissing", err)
+	}
+	if _, exists := links.GetTrash()["k8s"]; !exists {
+		t.Error("an alias that cannot be restored should stay in the trash")
+	}
+
+	if _, err := links.Restore(context.Background(), "kubernetes"); err != nil {
+		t.Fatalf("Restore(kubernetes) error = %v", err)
+	}
+	if _, err := links.Restore(context.Background(), "k8s"); err != nil {
+		t.Errorf("Restore(k8s) after its target error = %v", err)
+	}
+}
+
+func TestLinkMap_ReplaceAllValidatesAliases(t *testing.T) {
+	var chain strings.Builder
+	chain.WriteString("link0 https://deep.com\n")
+	for i := 1; i <= maxAliasDepth+1; i++ {
+		chain.WriteString(aliasName(i) + " - aliasOf=" + aliasName(i-1) + "\n")
+	}
+	tests := []struct {
+		name    string
+		file    string
+		wantErr error
+	}{
+		{name: "Accepts aliases declared before their target", file: "k8s - aliasOf=kubernetes\nkubernetes https://k8s.io\n"},
+		{name: "Rejects alias of a missing link", file: "k8s - aliasOf=kubernetes\n", wantErr: ErrAliasTargetMissing},
+		{name: "Rejects cycles", file: "a - aliasOf=b\nb - aliasOf=a\n", wantErr: ErrAliasCycle},
+		{name: "Rejects chains that are too deep", file: chain.String(), wantErr: ErrAliasTooDeep},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			links := NewLinkMap(storage.NONE, "")
+			err := links.ReplaceAll(context.Background(), strings.NewReader(tt.file))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ReplaceAll error = %v, want %v", err, tt.wantErr)
+			}
+			var validationErr *ValidationError
+			if tt.wantErr != nil && (!errors.As(err, &validationErr) || validationErr.Reason != ReasonInvalidAlias) {
+				t.Errorf("ReplaceAll error = %v, want a ValidationError with reason %s", err, ReasonInvalidAlias)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package links
+
+import (
+	"math"
+	"time"
+
+	"github.com/dfryer1193/golinks/models"
+)
+
+// RecordClick counts a visit to the link at path. Counts are kept in memory
+// and saved by the janitor.
+func (l *LinkMap) RecordClick(path string) {
+	l.clickLock.Lock()
+	defer l.clickLock.Unlock()
+
+	key := l.key(path)
+	now := l.now().UTC().Truncate(time.Second)
+	stats := l.clicks[key]
+	stats.Count++
+	stats.Popularity = l.decay(stats, now) + 1
+	stats.LastClickedAt = now
+	l.clicks[key] = stats
+	l.clicksDirty = true
+}
+
+// indexClicks re-keys click counts read from storage by their normalized path.
+func (l *LinkMap) indexClicks(clicks map[string]models.ClickStats) map[string]models.ClickStats {
+	indexed := make(map[string]models.ClickStats, len(clicks))
+	for path, stats := range clicks {
+		indexed[l.key(path)] = stats
+	}
+	return indexed
+}
+
+// flushClicks saves the click counts if they changed since the last flush.
+// Counts for links that no longer exist, either live or in the trash, are
+// dropped.
+func (l *LinkMap) flushClicks() {
+	l.clickLock.Lock()
+	if !l.clicksDirty {
+		l.clickLock.Unlock()
+		return
+	}
+	snapshot := make(map[string]models.ClickStats, len(l.clicks))
+	for key, stats := range l.clicks {
+		snapshot[key] = stats
+	}
+	l.clicksDirty = false
+	l.clickLock.Unlock()
+
+	l.mapLock.RLock()
+	byPath := make(map[string]models.ClickStats, len(snapshot))
+	for key, stats := range snapshot {
+		if entry, exists := l.m[key]; exists {
+			byPath[entry.Path] = stats
+		} else if entry, exists := l.trash[key]; exists {
+			byPath[entry.Path] = stats
+		}
+	}
+	l.mapLock.RUnlock()
+
+	l.store.WriteClicks(byPath)
+}
+
+// forgetClicks drops the click count for a key.
+func (l *LinkMap) forgetClicks(key string) {
+	l.clickLock.Lock()
+	defer l.clickLock.Unlock()
+
+	if _, exists := l.clicks[key]; exists {
+		delete(l.clicks, key)
+		l.clicksDirty = true
+	}
+}
+
+// popularity returns how popular the link at key is at the given time.
+func (l *LinkMap) popularity(key string, now time.Time) float64 {
+	l.clickLock.Lock()
+	defer l.clickLock.Unlock()
+
+	return l.decay(l.clicks[key], now)
+}
+
+// decay returns the popularity of stats at the given time. Popularity halves
+// every popularityHalfLife since the last click, so that links which were
+// popular once but are no longer used drift down the search results.
+func (l *LinkMap) decay(stats models.ClickStats, now time.Time) float64 {
+	if l.popularityHalfLife <= 0 || stats.Popularity == 0 {
+		return stats.Popularity
+	}
+	age := now.Sub(stats.LastClickedAt)
+	if age <= 0 {
+		return stats.Popularity
+	}
+	return stats.Popularity * math.Exp2(-float64(age)/float64(l.popularityHalfLife))
+}
+
+// clickStats returns the click count for a key.
+func (l *LinkMap) clickStats(key string) models.ClickStats {
+	l.clickLock.Lock()
+	defer l.clickLock.Unlock()
+
+	return l.clicks[key]
+}
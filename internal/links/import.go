@@ -0,0 +1,63 @@
+package links
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/dfryer1193/golinks/internal/links/storage"
+	"github.com/dfryer1193/golinks/models"
+)
+
+// validateImport checks every link of an imported file the way Put would, so
+// that an import cannot bring in links that could not be created one at a
+// time. The first problem found is returned as a ValidationError naming its
+// line.
+func (l *LinkMap) validateImport(ctx context.Context, lines []storage.Line) error {
+	paths := make(map[string]string, len(lines))
+	for _, line := range lines {
+		entry := line.Entry
+		if _, err := l.validateEntry(ctx, entry); err != nil {
+			return onLine(line, "", "", err)
+		}
+
+		// The same path may appear more than once, and the last line wins, but
+		// different spellings of one path would hide all but one of them.
+		key := l.key(entry.Path)
+		if existing, exists := paths[key]; exists && existing != entry.Path {
+			err := fmt.Errorf("%w: %s", ErrPathConflict, existing)
+			return onLine(line, "path", ReasonConflict, err)
+		}
+		paths[key] = entry.Path
+	}
+
+	imported := make(map[string]*models.Entry, len(lines))
+	for _, line := range lines {
+		imported[l.key(line.Entry.Path)] = line.Entry
+	}
+	now := l.now()
+	for _, line := range lines {
+		entry := line.Entry
+		if imported[l.key(entry.Path)] != entry {
+			continue
+		}
+		if _, err := l.resolveIn(imported, entry, nil, now, false); err != nil {
+			return onLine(line, "aliasOf", ReasonInvalidAlias, err)
+		}
+	}
+	return nil
+}
+
+// onLine places an error found in an imported file on the line it came from.
+// Errors that are not already a ValidationError are reported against field
+// with reason.
+func onLine(line storage.Line, field, reason string, err error) error {
+	located := &ValidationError{Field: field, Reason: reason, Message: err.Error(), Err: err}
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		*located = *validationErr
+	}
+	located.Path = line.Entry.Path
+	located.Line = line.Number
+	return located
+}
@@ -0,0 +1,193 @@
+package links
+
+import (
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dfryer1193/golinks/internal/links/storage"
+	"github.com/dfryer1193/golinks/models"
+)
+
+func TestLinkMap_ReplaceAllValidatesPaths(t *testing.T) {
+	tests := []struct {
+		name   string
+		file   string
+		line   int
+		reason string
+		err    error
+	}{
+		{
+			name:   "Rejects a reserved path",
+			file:   "docs https://docs.example.com\napi https://example.com\n",
+			line:   2,
+			reason: ReasonReserved,
+		},
+		{
+			name:   "Rejects characters outside the character set",
+			file:   "foo+ https://example.com\n",
+			line:   1,
+			reason: ReasonInvalidCharacter,
+		},
+		{
+			name:   "Rejects long paths",
+			file:   strings.Repeat("a", 17) + " https://example.com\n",
+			line:   1,
+			reason: ReasonTooLong,
+		},
+		{
+			name:   "Rejects a malformed line",
+			file:   "docs https://docs.example.com\nbroken\n",
+			line:   2,
+			reason: ReasonMalformed,
+		},
+		{
+			name:   "Rejects paths that normalize to the same key",
+			file:   "on-call https://oncall.com\n\nOnCall https://other.com\n",
+			line:   3,
+			reason: ReasonConflict,
+			err:    ErrPathConflict,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			links := NewLinkMap(storage.NONE, "", WithPathPolicy(PathPolicy{
+				Reserved:     []string{"api"},
+				AllowedChars: DefaultPathChars,
+				MaxLength:    16,
+			}))
+			links.Put(context.Background(), &models.Entry{Path: "wiki", Target: "https://wiki.example.com"})
+
+			err := links.ReplaceAll(context.Background(), strings.NewReader(tt.file))
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("ReplaceAll error = %v, want a ValidationError", err)
+			}
+			if validationErr.Line != tt.line || validationErr.Reason != tt.reason {
+				t.Errorf("got line %d/%s, want line %d/%s", validationErr.Line, validationErr.Reason, tt.line, tt.reason)
+			}
+			if tt.err != nil && !errors.Is(err, tt.err) {
+				t.Errorf("ReplaceAll error = %v, want %v", err, tt.err)
+			}
+			if _, exists := links.Get("wiki"); !exists {
+				t.Error("a rejected import should leave the existing links in place")
+			}
+		})
+	}
+}
+
+func TestLinkMap_ReplaceAllKeepsLastDuplicate(t *testing.T) {
+	links := NewLinkMap(storage.NONE, "")
+	err := links.ReplaceAll(context.Background(), strings.NewReader("docs https://old.example.com\ndocs https://new.example.com\n"))
+	if err != nil {
+		t.Fatalf("ReplaceAll: %v", err)
+	}
+	if target, _ := links.Get("docs"); target != "https://new.example.com" {
+		t.Errorf("Get(docs) = %s, want the last line's target", target)
+	}
+}
+
+func TestLinkMap_ReplaceAllValidatesTargets(t *testing.T) {
+	tests := []struct {
+		name   string
+		file   string
+		field  string
+		reason string
+	}{
+		{
+			name:   "Rejects javascript targets",
+			file:   "docs https://docs.example.com\nxss javascript:alert(1)\n",
+			field:  "target",
+			reason: ReasonSchemeNotAllowed,
+		},
+		{
+			name:   "Rejects data targets",
+			file:   "docs https://docs.example.com\nxss data:text/html,hi\n",
+			field:  "target",
+			reason: ReasonSchemeNotAllowed,
+		},
+		{
+			name:   "Rejects denied domains",
+			file:   "docs https://docs.example.com\nbad https://evil.example.org\n",
+			field:  "target",
+			reason: ReasonDomainDenied,
+		},
+		{
+			name:   "Rejects private addresses",
+			file:   "docs https://docs.example.com\nrouter http://192.168.1.1\n",
+			field:  "target",
+			reason: ReasonPrivateAddress,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			links := NewLinkMap(storage.NONE, "", WithTargetPolicy(TargetPolicy{
+				AllowedSchemes:        []string{"http", "https"},
+				DeniedDomains:         []string{"evil.example.org"},
+				BlockPrivateAddresses: true,
+			}))
+
+			err := links.ReplaceAll(context.Background(), strings.NewReader(tt.file))
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("ReplaceAll error = %v, want a ValidationError", err)
+			}
+			if validationErr.Line != 2 || validationErr.Field != tt.field || validationErr.Reason != tt.reason {
+				t.Errorf("got line %d %s/%s, want line 2 %s/%s", validationErr.Line, validationErr.Field, validationErr.Reason, tt.field, tt.reason)
+			}
+			if _, exists := links.Get("docs"); exists {
+				t.Error("a rejected import should not add any of its links")
+			}
+		})
+	}
+}
+
+func TestLinkMap_ReplaceAllWaitsForQueuedWrites(t *testing.T) {
+	path := t.TempDir() + "/links"
+	if err := os.WriteFile(path, []byte("foo https://foo.com\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	file := storage.NewFileStorage(path)
+	store := &stalledStorage{Storage: file, release: make(chan struct{})}
+	links := NewLinkMap(storage.FILE, path, withStore(store))
+	ctx := context.Background()
+
+	if err := links.Put(ctx, &models.Entry{Path: "x", Target: "https://x.com"}); err != nil {
+		t.Fatal(err)
+	}
+	imported := make(chan error)
+	go func() {
+		imported <- links.ReplaceAll(ctx, strings.NewReader("bar https://bar.com\n"))
+	}()
+	time.Sleep(50 * time.Millisecond)
+	close(store.release)
+	if err := <-imported; err != nil {
+		t.Fatalf("ReplaceAll error = %v", err)
+	}
+	if err := links.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	saved, err := file.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var savedPaths []string
+	for path := range saved {
+		savedPaths = append(savedPaths, path)
+	}
+	slices.Sort(savedPaths)
+	livePaths := links.GetAllKeys()
+	slices.Sort(livePaths)
+	if !reflect.DeepEqual(livePaths, savedPaths) {
+		t.Errorf("links = %v, but the file holds %v", livePaths, savedPaths)
+	}
+	if want := []string{"bar"}; !reflect.DeepEqual(savedPaths, want) {
+		t.Errorf("file holds %v, want %v", savedPaths, want)
+	}
+}
@@ -1,53 +1,187 @@
 package links
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"github.com/dfryer1193/golinks/internal/links/storage"
+	"github.com/dfryer1193/golinks/internal/normalize"
+	"github.com/dfryer1193/golinks/internal/search"
+	"github.com/dfryer1193/golinks/models"
 	"github.com/rs/zerolog/log"
 	"io"
-	"net/url"
+	"net"
+	"strings"
 	"sync"
+	"time"
+)
+
+const (
+	defaultArchiveGracePeriod = 7 * 24 * time.Hour
+	defaultTrashRetention     = 30 * 24 * time.Hour
+	defaultPopularityHalfLife = 30 * 24 * time.Hour
+	janitorInterval           = time.Minute
+)
+
+var (
+	// ErrInvalidSchedule is returned when a link would expire before it
+	// becomes active.
+	ErrInvalidSchedule = errors.New("link must become active before it expires")
+	// ErrNotInTrash is returned when restoring a path that is not in the trash.
+	ErrNotInTrash = errors.New("link is not in the trash")
+	// ErrLinkExists is returned when restoring over a live link.
+	ErrLinkExists = errors.New("a link with this path already exists")
+	// ErrNotFound is returned when a link does not exist or is not active.
+	ErrNotFound = errors.New("link not found")
+	// ErrPathConflict is returned when a new path normalizes to the same key as
+	// an existing link with a different spelling.
+	ErrPathConflict = errors.New("path conflicts with an existing link")
+	// ErrClosed is returned when changing links after the LinkMap is closed.
+	ErrClosed = errors.New("link map is closed")
 )
 
 type ParseError struct{}
 
 // LinkMap houses the map of redirects, and keeps track of the backing file for
-// maintaining the map across restarts. It also handles thread safety.
+// maintaining the map across restarts. It also handles thread safety. Entries
+// are keyed by their normalized path, while each entry keeps the path as it
+// was written.
 type LinkMap struct {
-	store   storage.Storage
-	m       map[string]string
-	mapLock *sync.RWMutex
+	store              storage.Storage
+	m                  map[string]*models.Entry
+	trash              map[string]*models.Entry
+	mapLock            *sync.RWMutex
+	normalizer         *normalize.Normalizer
+	pathPolicy         PathPolicy
+	pathValidator      *pathValidator
+	targetPolicy       TargetPolicy
+	lookupIP           func(ctx context.Context, host string) ([]net.IP, error)
+	archiveGracePeriod time.Duration
+	trashRetention     time.Duration
+	popularityHalfLife time.Duration
+	clicks             map[string]models.ClickStats
+	clickLock          *sync.Mutex
+	clicksDirty        bool
+	searchIndex        *search.Index
+	reloadErr          error
+	now                func() time.Time
+	// generation counts the changes made to links through this LinkMap, so
+	// that a reload can tell whether the links it read are still current.
+	generation uint64
+	// pendingWrites counts the storage writes in progress. Storage cannot be
+	// trusted to hold every link while any are, so reloads wait for them to
+	// finish, with reloadDeferred set.
+	pendingWrites  int
+	reloadDeferred bool
+	// closed is set once Close is called, after which links cannot change.
+	closed bool
+	// writes tracks storage writes that are still in progress.
+	writes *sync.WaitGroup
+	// lastWrite is closed once the last write handed to persist is done.
+	lastWrite   chan struct{}
+	stopJanitor chan struct{}
+	janitorDone chan struct{}
+}
+
+// Option configures optional LinkMap behaviour.
+type Option func(*LinkMap)
+
+// WithArchiveGracePeriod sets how long an expired link is kept before the
+// janitor archives it.
+func WithArchiveGracePeriod(gracePeriod time.Duration) Option {
+	return func(l *LinkMap) {
+		l.archiveGracePeriod = gracePeriod
+	}
+}
+
+// WithTrashRetention sets how long a deleted link stays restorable before the
+// janitor purges it.
+func WithTrashRetention(retention time.Duration) Option {
+	return func(l *LinkMap) {
+		l.trashRetention = retention
+	}
+}
+
+// WithPopularityHalfLife sets how long it takes for the popularity a link got
+// from its clicks to halve. Zero or less keeps popularity from decaying.
+func WithPopularityHalfLife(halfLife time.Duration) Option {
+	return func(l *LinkMap) {
+		l.popularityHalfLife = halfLife
+	}
+}
+
+// WithNormalizer sets how paths are normalized before they are stored and
+// matched.
+func WithNormalizer(normalizer *normalize.Normalizer) Option {
+	return func(l *LinkMap) {
+		l.normalizer = normalizer
+	}
 }
 
 // NewLinkMap generates a new LinkMap object, with the requested config if it
 // exists. If the requested config does not exist, it falls back to the default
 // locations. If the default locations do not exist, the program will exit with
 // an error.
-func NewLinkMap(persistType storage.StorageType, requestedConfig string) *LinkMap {
-	store := buildStorage(persistType, requestedConfig)
-	m, err := store.Read()
+func NewLinkMap(persistType storage.StorageType, requestedConfig string, opts ...Option) *LinkMap {
+	linkMap := LinkMap{
+		mapLock:            &sync.RWMutex{},
+		normalizer:         normalize.Default(),
+		pathPolicy:         DefaultPathPolicy(),
+		targetPolicy:       DefaultTargetPolicy(),
+		lookupIP:           lookupIP,
+		archiveGracePeriod: defaultArchiveGracePeriod,
+		trashRetention:     defaultTrashRetention,
+		popularityHalfLife: defaultPopularityHalfLife,
+		clickLock:          &sync.Mutex{},
+		now:                time.Now,
+		writes:             &sync.WaitGroup{},
+		stopJanitor:        make(chan struct{}),
+		janitorDone:        make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(&linkMap)
+	}
+
+	// The storage is built once the normalizer is known, since it keys the
+	// trash by normalized path.
+	if linkMap.store == nil {
+		linkMap.store = buildStorage(persistType, requestedConfig, linkMap.key)
+	}
+	m, err := linkMap.store.Read()
 	if err != nil {
 		panic(err)
 	}
-
-	linkMap := LinkMap{
-		store:   store,
-		m:       m,
-		mapLock: &sync.RWMutex{},
+	trash, err := linkMap.store.ReadTrash()
+	if err != nil {
+		panic(err)
+	}
+	clicks, err := linkMap.store.ReadClicks()
+	if err != nil {
+		panic(err)
 	}
 
+	linkMap.pathValidator = linkMap.buildPathValidator()
+	linkMap.m = linkMap.index(m)
+	linkMap.trash = linkMap.index(trash)
+	linkMap.clicks = linkMap.indexClicks(clicks)
+	linkMap.searchIndex = linkMap.buildSearchIndex(linkMap.m)
+
 	go linkMap.handleReload()
+	go linkMap.runJanitor()
 
 	return &linkMap
 }
 
-func buildStorage(persistType storage.StorageType, requestedConfig string) storage.Storage {
+func buildStorage(persistType storage.StorageType, requestedConfig string, key func(string) string) storage.Storage {
 	switch persistType {
 	case storage.NONE:
 		return storage.NewNoneStorage()
 	case storage.FILE:
-		return storage.NewFileStorage(requestedConfig)
+		return storage.NewFileStorage(requestedConfig, storage.WithTrashKey(key))
 	default:
-		return storage.NewFileStorage("")
+		return storage.NewFileStorage("", storage.WithTrashKey(key))
 	}
 }
 
@@ -65,57 +199,320 @@ func (l *LinkMap) handleReload() {
 	}
 }
 
+// reload replaces the links with those in storage. Reading the links and
+// indexing them for search can take a while, so it is done without holding
+// mapLock, and the result is swapped in only if the links were not changed in
+// the meantime. If they were, or storage writes are still in progress, the
+// reload is retried once storage is up to date.
 func (l *LinkMap) reload() {
+	ctx, span := tracer.Start(context.Background(), "LinkMap.reload")
+	defer span.End()
+
+	for {
+		l.mapLock.Lock()
+		if l.pendingWrites > 0 {
+			l.reloadDeferred = true
+			l.mapLock.Unlock()
+			return
+		}
+		generation := l.generation
+		l.mapLock.Unlock()
+
+		newMap, err := traceStorage(ctx, "Read", l.store.Read)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to reload link map from storage.")
+			l.mapLock.Lock()
+			l.reloadErr = err
+			l.mapLock.Unlock()
+			return
+		}
+		m := l.index(newMap)
+		searchIndex := l.buildSearchIndex(m)
+
+		l.mapLock.Lock()
+		if l.generation == generation {
+			l.m = m
+			l.searchIndex = searchIndex
+			l.reloadErr = nil
+			l.mapLock.Unlock()
+			return
+		}
+		l.mapLock.Unlock()
+	}
+}
+
+// Check returns an error if the links being served may be out of date, because
+// the storage can no longer be read or watched, or the last reload failed.
+func (l *LinkMap) Check() error {
+	if err := l.store.Check(); err != nil {
+		return err
+	}
+
+	l.mapLock.RLock()
+	defer l.mapLock.RUnlock()
+	if l.reloadErr != nil {
+		return fmt.Errorf("reloading links: %w", l.reloadErr)
+	}
+	return nil
+}
+
+// key returns the normalized key a path is stored under.
+func (l *LinkMap) key(path string) string {
+	return l.normalizer.Normalize(path)
+}
+
+// index re-keys entries read from storage by their normalized path. If two
+// entries collide after normalization, the one read last wins.
+func (l *LinkMap) index(entries map[string]*models.Entry) map[string]*models.Entry {
+	indexed := make(map[string]*models.Entry, len(entries))
+	for _, entry := range entries {
+		key := l.key(entry.Path)
+		if existing, exists := indexed[key]; exists {
+			log.Warn().
+				Str("path", entry.Path).
+				Str("conflictsWith", existing.Path).
+				Msg("Links collide after normalization; only one will be reachable")
+		}
+		indexed[key] = entry
+	}
+	return indexed
+}
+
+func (l *LinkMap) runJanitor() {
+	defer close(l.janitorDone)
+	ticker := time.NewTicker(janitorInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.stopJanitor:
+			return
+		case <-ticker.C:
+			l.archiveExpired()
+			l.purgeTrash()
+			l.flushClicks()
+		}
+	}
+}
+
+// Close stops accepting changes to links, waits for the writes still in
+// progress to reach storage, saves the click counts and closes the storage. If
+// ctx ends first, Close stops waiting but still saves the click counts and
+// closes the storage, and reports that the writes still in progress may be
+// lost.
+func (l *LinkMap) Close(ctx context.Context) error {
+	l.mapLock.Lock()
+	if l.closed {
+		l.mapLock.Unlock()
+		return nil
+	}
+	l.closed = true
+	l.mapLock.Unlock()
+
+	close(l.stopJanitor)
+	<-l.janitorDone
+
+	written := make(chan struct{})
+	go func() {
+		l.writes.Wait()
+		close(written)
+	}()
+	var waitErr error
+	select {
+	case <-written:
+	case <-ctx.Done():
+		waitErr = fmt.Errorf("waiting for pending writes: %w", ctx.Err())
+	}
+	l.flushClicks()
+
+	return errors.Join(waitErr, l.store.Close())
+}
+
+// persist runs a storage write in the background, in a span that stays part of
+// the trace of the operation that caused it. Writes reach storage in the order
+// they were made, so that, for example, trashing a link cannot overtake the
+// write that created it. Close waits for the write to finish. Callers must hold
+// mapLock and have checked that the LinkMap is not closed.
+func (l *LinkMap) persist(ctx context.Context, name string, write func()) {
+	_, span := tracer.Start(ctx, "storage."+name)
+	l.startWrite()
+	previous := l.lastWrite
+	done := make(chan struct{})
+	l.lastWrite = done
+	go func() {
+		defer l.finishWrite()
+		defer close(done)
+		if previous != nil {
+			<-previous
+		}
+		defer span.End()
+		write()
+	}()
+}
+
+// startWrite records a change to the links that is about to be written to
+// storage. Callers must hold mapLock.
+func (l *LinkMap) startWrite() {
+	l.writes.Add(1)
+	l.pendingWrites++
+	l.generation++
+}
+
+// finishWrite records that a write begun with startWrite reached storage, and
+// runs the reload put off while it was in progress, if any. The reload runs
+// before Close stops waiting on the write, so that Close saves click counts for
+// the links storage ends up with.
+func (l *LinkMap) finishWrite() {
+	defer l.writes.Done()
+
+	l.mapLock.Lock()
+	l.pendingWrites--
+	reload := l.pendingWrites == 0 && l.reloadDeferred
+	if reload {
+		l.reloadDeferred = false
+	}
+	l.mapLock.Unlock()
+
+	if reload {
+		l.reload()
+	}
+}
+
+// archiveExpired removes links whose expiry is older than the grace period from
+// the live map and hands them to the store for archiving. The aliases that
+// lead to an archived link stopped resolving when it expired, so they are
+// archived along with it rather than left dangling.
+func (l *LinkMap) archiveExpired() {
+	cutoff := l.now().Add(-l.archiveGracePeriod)
+
 	l.mapLock.Lock()
 	defer l.mapLock.Unlock()
-	newMap, err := l.store.Read()
-	if err != nil {
-		log.Error().Err(err).Msg("Failed to reload link map from storage.")
+	if l.closed {
 		return
 	}
+	var expired []string
+	for key, entry := range l.m {
+		if entry.ExpiresAt != nil && !entry.ExpiresAt.After(cutoff) {
+			expired = append(expired, key)
+		}
+	}
 
-	l.m = newMap
+	for _, key := range expired {
+		entry, exists := l.m[key]
+		if !exists {
+			continue
+		}
+		log.Info().Str("key", key).Time("expiresAt", *entry.ExpiresAt).Msg("Archiving expired link")
+		l.archive(key)
+	}
 }
 
-// Get returns the url and state of existence for a single key.
+// archive removes the link with the given key, and the aliases leading to it,
+// from the live map and hands them to the store for archiving. Callers must
+// hold mapLock.
+func (l *LinkMap) archive(key string) {
+	entry := l.m[key]
+	l.persist(context.Background(), "Archive", func() { l.store.Archive(entry) })
+	delete(l.m, key)
+	l.searchIndex.Remove(entry.Path)
+
+	for _, alias := range l.aliasesOf(key) {
+		log.Info().Str("key", l.key(alias)).Str("aliasOf", entry.Path).Msg("Archiving alias of expired link")
+		l.archive(l.key(alias))
+	}
+}
+
+// purgeTrash permanently removes links that have been in the trash for longer
+// than the retention period.
+func (l *LinkMap) purgeTrash() {
+	cutoff := l.now().Add(-l.trashRetention)
+
+	l.mapLock.Lock()
+	defer l.mapLock.Unlock()
+	if l.closed {
+		return
+	}
+	for key, entry := range l.trash {
+		if entry.DeletedAt != nil && entry.DeletedAt.After(cutoff) {
+			continue
+		}
+
+		log.Info().Str("key", key).Msg("Purging link from trash")
+		path := entry.Path
+		l.persist(context.Background(), "Purge", func() { l.store.Purge(path) })
+		delete(l.trash, key)
+		// A live link recreated under the same key keeps its own clicks.
+		if _, live := l.m[key]; !live {
+			l.forgetClicks(key)
+		}
+	}
+}
+
+// Get returns the url and state of existence for a single key, following
+// aliases to their canonical link. Links that are not yet active or have
+// expired are reported as absent.
 func (l *LinkMap) Get(key string) (string, bool) {
+	canonical, err := l.Resolve(context.Background(), key)
+	if err != nil {
+		return "", false
+	}
+	return canonical.Target, true
+}
+
+// GetEntry returns a copy of the entry for a single key regardless of its
+// schedule, with its current state filled in.
+func (l *LinkMap) GetEntry(key string) (*models.Entry, bool) {
 	l.mapLock.RLock()
 	defer l.mapLock.RUnlock()
 
-	target, exists := l.m[key]
-	return target, exists
+	entry, exists := l.m[l.key(key)]
+	if !exists {
+		return nil, false
+	}
+	return l.withState(entry), true
 }
 
-// GetAll returns a map containing all of the entries from the current LinkMap object
-func (l *LinkMap) GetAll() map[string]string {
+// GetAll returns a map containing copies of all of the entries from the current
+// LinkMap object
+func (l *LinkMap) GetAll() map[string]*models.Entry {
 	l.mapLock.RLock()
 	defer l.mapLock.RUnlock()
 
-	return l.m
+	all := make(map[string]*models.Entry, len(l.m))
+	for _, entry := range l.m {
+		all[entry.Path] = l.withState(entry)
+	}
+	return all
 }
 
+// GetAllKeys returns the paths of all entries as they were written.
 func (l *LinkMap) GetAllKeys() []string {
 	l.mapLock.RLock()
 	defer l.mapLock.RUnlock()
 
 	keys := make([]string, len(l.m))
 	i := 0
-	for key := range l.m {
-		keys[i] = key
+	for _, entry := range l.m {
+		keys[i] = entry.Path
 		i++
 	}
 
 	return keys
 }
 
-func (l *LinkMap) GetFiltered(keys []string) map[string]string {
-	filteredMap := make(map[string]string, len(keys))
+// Normalize returns the key a path is matched under.
+func (l *LinkMap) Normalize(path string) string {
+	return l.key(path)
+}
+
+func (l *LinkMap) GetFiltered(keys []string) map[string]*models.Entry {
+	filteredMap := make(map[string]*models.Entry, len(keys))
 
 	l.mapLock.RLock()
 	defer l.mapLock.RUnlock()
 	for _, key := range keys {
-		if v, exists := l.m[key]; exists {
-			filteredMap[key] = v
+		if v, exists := l.m[l.key(key)]; exists {
+			filteredMap[v.Path] = l.withState(v)
 		}
 	}
 
@@ -125,54 +522,263 @@ func (l *LinkMap) GetFiltered(keys []string) map[string]string {
 // Put appends a new entry to the link map. If the entry already exists, it will
 // be duplicated in the backing file, and the value in the live map will be
 // replaced.
-func (l *LinkMap) Put(key string, target *url.URL) error {
-	go l.store.Put(key, target.String())
+func (l *LinkMap) Put(ctx context.Context, entry *models.Entry) (err error) {
+	ctx, span := startSpan(ctx, "Put", entry.Path)
+	defer func() { endSpan(span, err) }()
+
+	entry, err = l.validateEntry(ctx, entry)
+	if err != nil {
+		return err
+	}
 
 	l.mapLock.Lock()
 	defer l.mapLock.Unlock()
-	l.m[key] = target.String()
+	if l.closed {
+		return ErrClosed
+	}
+	if err := l.checkConflict(entry); err != nil {
+		return err
+	}
+	if err := l.validateAlias(entry); err != nil {
+		return err
+	}
+	l.stamp(entry)
+
+	l.persist(ctx, "Put", func() { l.store.Put(entry) })
+	key := l.key(entry.Path)
+	if _, exists := l.m[key]; !exists {
+		// A new link does not inherit the clicks of a trashed one with the
+		// same key.
+		l.forgetClicks(key)
+	}
+	l.m[key] = entry
+	l.searchIndex.Add(searchDocument(entry))
 
 	return nil
 }
 
-// Delete removes an entry from the link map. If the key is not present in the
-// map, this is a no-op
-func (l *LinkMap) Delete(key string) error {
+// Delete moves an entry from the link map to the trash, where it can be
+// restored until the janitor purges it. If the key is not present in the map,
+// this is a no-op. A link cannot be deleted while aliases point at it.
+func (l *LinkMap) Delete(ctx context.Context, key string) (err error) {
+	ctx, span := startSpan(ctx, "Delete", key)
+	defer func() { endSpan(span, err) }()
+
+	l.mapLock.Lock()
+	defer l.mapLock.Unlock()
+	if l.closed {
+		return ErrClosed
+	}
+
 	// Can skip filesystem-intensive writes if the entry already doesn't exist
-	l.mapLock.RLock()
-	if _, exists := l.m[key]; !exists {
-		l.mapLock.RUnlock()
+	key = l.key(key)
+	entry, exists := l.m[key]
+	if !exists {
 		return nil
 	}
-	l.mapLock.RUnlock()
+	if aliases := l.aliasesOf(key); len(aliases) > 0 {
+		return fmt.Errorf("%w: %s", ErrHasAliases, strings.Join(aliases, ", "))
+	}
 
-	go l.store.Delete(key)
-	l.mapLock.Lock()
+	trashed := entry.Clone()
+	deletedAt := l.now().UTC().Truncate(time.Second)
+	trashed.DeletedAt = &deletedAt
+
+	l.persist(ctx, "Trash", func() { l.store.Trash(trashed) })
 	delete(l.m, key)
-	l.mapLock.Unlock()
+	l.searchIndex.Remove(entry.Path)
+	l.trash[key] = trashed
 	return nil
 }
 
+// GetTrash returns copies of all entries currently in the trash.
+func (l *LinkMap) GetTrash() map[string]*models.Entry {
+	l.mapLock.RLock()
+	defer l.mapLock.RUnlock()
+
+	trash := make(map[string]*models.Entry, len(l.trash))
+	for _, entry := range l.trash {
+		trash[entry.Path] = entry.Clone()
+	}
+	return trash
+}
+
+// Restore moves an entry from the trash back into the link map and returns the
+// restored entry.
+func (l *LinkMap) Restore(ctx context.Context, key string) (_ *models.Entry, err error) {
+	ctx, span := startSpan(ctx, "Restore", key)
+	defer func() { endSpan(span, err) }()
+
+	l.mapLock.Lock()
+	defer l.mapLock.Unlock()
+	if l.closed {
+		return nil, ErrClosed
+	}
+
+	key = l.key(key)
+	trashed, exists := l.trash[key]
+	if !exists {
+		return nil, ErrNotInTrash
+	}
+	if _, exists := l.m[key]; exists {
+		return nil, ErrLinkExists
+	}
+
+	restored := trashed.Clone()
+	restored.DeletedAt = nil
+	if err := l.validateAlias(restored); err != nil {
+		return nil, err
+	}
+
+	l.persist(ctx, "Restore", func() { l.store.Restore(restored) })
+	delete(l.trash, key)
+	l.m[key] = restored
+	l.searchIndex.Add(searchDocument(restored))
+	return l.withState(restored), nil
+}
+
 // Update updates an existing entry in the link map. This should only be used to
 // update existing entries, as Put is much more efficient for additions.
-func (l *LinkMap) Update(key string, target *url.URL) error {
-	go l.store.Update(key, target.String())
+func (l *LinkMap) Update(ctx context.Context, entry *models.Entry) (err error) {
+	ctx, span := startSpan(ctx, "Update", entry.Path)
+	defer func() { endSpan(span, err) }()
+
+	entry, err = l.validateEntry(ctx, entry)
+	if err != nil {
+		return err
+	}
 
 	l.mapLock.Lock()
 	defer l.mapLock.Unlock()
+	if l.closed {
+		return ErrClosed
+	}
+	if err := l.checkConflict(entry); err != nil {
+		return err
+	}
+	if err := l.validateAlias(entry); err != nil {
+		return err
+	}
+	l.stamp(entry)
 
-	l.m[key] = target.String()
+	l.persist(ctx, "Update", func() { l.store.Update(entry) })
+	l.m[l.key(entry.Path)] = entry
+	l.searchIndex.Add(searchDocument(entry))
 	return nil
 }
 
-func (l *LinkMap) ReplaceAll(mapReader io.Reader) error {
-	newMap, err := l.store.ReplaceConfig(mapReader)
+// ReplaceAll replaces every link with those read from mapReader. Nothing is
+// replaced if any of the links would be rejected by Put.
+func (l *LinkMap) ReplaceAll(ctx context.Context, mapReader io.Reader) (err error) {
+	ctx, span := tracer.Start(ctx, "LinkMap.ReplaceAll")
+	defer func() { endSpan(span, err) }()
+
+	data, err := io.ReadAll(mapReader)
+	if err != nil {
+		return err
+	}
+	lines, err := storage.ParseLines(bytes.NewReader(data))
+	var parseErr *storage.ParseError
+	if errors.As(err, &parseErr) {
+		return &ValidationError{Field: "line", Reason: ReasonMalformed, Message: parseErr.Err.Error(), Err: err, Line: parseErr.Line}
+	}
 	if err != nil {
 		return err
 	}
+	if err := l.validateImport(ctx, lines); err != nil {
+		return err
+	}
+
+	imported := make(map[string]*models.Entry, len(lines))
+	for _, line := range lines {
+		imported[line.Entry.Path] = line.Entry
+	}
+	m := l.index(imported)
+	searchIndex := l.buildSearchIndex(m)
+
+	// The file is rewritten in turn with the other writes, so that a change
+	// still waiting to be written cannot land in the imported file after the
+	// links were replaced.
+	var writeErr error
+	written := make(chan struct{})
 	l.mapLock.Lock()
-	defer l.mapLock.Unlock()
-	l.m = newMap
+	if l.closed {
+		l.mapLock.Unlock()
+		return ErrClosed
+	}
+	l.persist(ctx, "ReplaceConfig", func() {
+		defer close(written)
+		_, writeErr = l.store.ReplaceConfig(bytes.NewReader(data))
+	})
+	l.m = m
+	l.searchIndex = searchIndex
+	l.mapLock.Unlock()
+
+	<-written
+	return writeErr
+}
+
+// checkConflict rejects entries whose path normalizes to the key of an
+// existing link spelled differently. Callers must hold mapLock.
+func (l *LinkMap) checkConflict(entry *models.Entry) error {
+	existing, exists := l.m[l.key(entry.Path)]
+	if exists && existing.Path != entry.Path {
+		return fmt.Errorf("%w: %s", ErrPathConflict, existing.Path)
+	}
+	return nil
+}
+
+// stamp sets the entry's creation and modification times, keeping the creation
+// time of the link it replaces. Callers must hold mapLock.
+func (l *LinkMap) stamp(entry *models.Entry) {
+	now := l.now().UTC().Truncate(time.Second)
+	entry.CreatedAt = &now
+	entry.UpdatedAt = &now
+	if existing, exists := l.m[l.key(entry.Path)]; exists && existing.CreatedAt != nil {
+		entry.CreatedAt = existing.CreatedAt
+	}
+}
+
+// withState returns a copy of the entry with its current state and click count
+// filled in. For aliases, the target is that of the canonical link, or empty if
+// the alias cannot currently be resolved. Callers must hold mapLock.
+func (l *LinkMap) withState(entry *models.Entry) *models.Entry {
+	now := l.now()
+	withState := entry.Clone()
+	withState.State = entry.StateAt(now)
+	clicks := l.clickStats(l.key(entry.Path))
+	withState.Clicks = clicks.Count
+	if clicks.Count > 0 {
+		withState.LastClickedAt = &clicks.LastClickedAt
+	}
+	if entry.AliasOf != "" {
+		withState.Target = ""
+		if canonical, err := l.resolveLocked(entry, nil, now, false); err == nil {
+			withState.Target = canonical.Target
+		}
+	}
+	return withState
+}
+
+// stored returns the copy of an entry that is kept in the map. State and click
+// counts are derived on read, so they are never persisted with the entry.
+func stored(entry *models.Entry) *models.Entry {
+	s := entry.Clone()
+	s.State = ""
+	s.Clicks = 0
+	s.LastClickedAt = nil
+	return s
+}
+
+func validateSchedule(entry *models.Entry) error {
+	if entry.ActiveFrom != nil && entry.ExpiresAt != nil && !entry.ActiveFrom.Before(*entry.ExpiresAt) {
+		return &ValidationError{
+			Field:   "schedule",
+			Reason:  ReasonInvalidSchedule,
+			Message: ErrInvalidSchedule.Error(),
+			Err:     ErrInvalidSchedule,
+		}
+	}
 	return nil
 }
 
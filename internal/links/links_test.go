@@ -1,17 +1,26 @@
 package links
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"github.com/dfryer1193/golinks/internal/links/storage"
+	"github.com/dfryer1193/golinks/internal/normalize"
+	"github.com/dfryer1193/golinks/models"
 	"github.com/rs/zerolog/log"
 	"net/url"
+	"os"
 	"reflect"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestLinkMap_Delete(t *testing.T) {
 	links := NewLinkMap(storage.NONE, "")
-	links.Put("foo", &url.URL{Scheme: "https", Host: "foo.com"})
-	links.Put("bar", &url.URL{Scheme: "https", Host: "bar.com"})
+	links.Put(context.Background(), &models.Entry{Path: "foo", Target: "https://foo.com"})
+	links.Put(context.Background(), &models.Entry{Path: "bar", Target: "https://bar.com"})
 	tests := []struct {
 		name    string
 		key     string
@@ -23,7 +32,7 @@ func TestLinkMap_Delete(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			links.Delete(tt.key)
+			links.Delete(context.Background(), tt.key)
 			if _, existsActual := links.Get(tt.key); tt.present != existsActual {
 				log.Fatal().Msgf("Expected entry %s to not be present", tt.key)
 			}
@@ -33,8 +42,8 @@ func TestLinkMap_Delete(t *testing.T) {
 
 func TestLinkMap_Get(t *testing.T) {
 	links := NewLinkMap(storage.NONE, "")
-	links.Put("foo", &url.URL{Scheme: "https", Host: "foo.com"})
-	links.Put("bar", &url.URL{Scheme: "https", Host: "bar.com"})
+	links.Put(context.Background(), &models.Entry{Path: "foo", Target: "https://foo.com"})
+	links.Put(context.Background(), &models.Entry{Path: "bar", Target: "https://bar.com"})
 	tests := []struct {
 		name    string
 		key     string
@@ -66,9 +75,9 @@ func TestLinkMap_Get(t *testing.T) {
 
 func TestLinkMap_GetFiltered(t *testing.T) {
 	links := NewLinkMap(storage.NONE, "")
-	links.Put("foo", &url.URL{Scheme: "https", Host: "foo.com"})
-	links.Put("bar", &url.URL{Scheme: "https", Host: "bar.com"})
-	links.Put("foobar", &url.URL{Scheme: "https", Host: "foobar.com"})
+	links.Put(context.Background(), &models.Entry{Path: "foo", Target: "https://foo.com"})
+	links.Put(context.Background(), &models.Entry{Path: "bar", Target: "https://bar.com"})
+	links.Put(context.Background(), &models.Entry{Path: "foobar", Target: "https://foobar.com"})
 	tests := []struct {
 		name     string
 		keys     []string
@@ -99,7 +108,10 @@ func TestLinkMap_GetFiltered(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			actual := links.GetFiltered(tt.keys)
+			actual := make(map[string]string)
+			for key, entry := range links.GetFiltered(tt.keys) {
+				actual[key] = entry.Target
+			}
 			if !reflect.DeepEqual(actual, tt.expected) {
 				log.Fatal().Msgf("Expected entry %s to contain %s, got %s instead.", actual, tt.expected, actual)
 			}
@@ -119,7 +131,7 @@ func TestLinkMap_Put(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			links.Put(tt.key, tt.value)
+			links.Put(context.Background(), &models.Entry{Path: tt.key, Target: tt.value.String()})
 			if val, exists := links.Get(tt.key); !exists || val != tt.value.String() {
 				log.Fatal().Msgf("Expected entry %s to contain %s, got %s instead.", tt.key, tt.value, val)
 			}
@@ -139,10 +151,546 @@ func TestLinkMap_Update(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			links.Update(tt.key, tt.value)
+			links.Update(context.Background(), &models.Entry{Path: tt.key, Target: tt.value.String()})
 			if val, exists := links.Get(tt.key); !exists || val != tt.value.String() {
 				log.Fatal().Msgf("Expected entry %s to contain %s, got %s instead.", tt.key, tt.value, val)
 			}
 		})
 	}
 }
+
+func TestLinkMap_GetRespectsSchedule(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	past := now.Add(-time.Hour)
+	future := now.Add(time.Hour)
+
+	links := NewLinkMap(storage.NONE, "")
+	links.now = func() time.Time { return now }
+	links.Put(context.Background(), &models.Entry{Path: "pending", Target: "https://pending.com", ActiveFrom: &future})
+	links.Put(context.Background(), &models.Entry{Path: "expired", Target: "https://expired.com", ExpiresAt: &past})
+	links.Put(context.Background(), &models.Entry{Path: "window", Target: "https://window.com", ActiveFrom: &past, ExpiresAt: &future})
+	links.Put(context.Background(), &models.Entry{Path: "forever", Target: "https://forever.com"})
+	tests := []struct {
+		name    string
+		key     string
+		present bool
+		state   models.LinkState
+	}{
+		{name: "Pending link does not resolve", key: "pending", present: false, state: models.StatePending},
+		{name: "Expired link does not resolve", key: "expired", present: false, state: models.StateExpired},
+		{name: "Link inside its window resolves", key: "window", present: true, state: models.StateActive},
+		{name: "Unscheduled link resolves", key: "forever", present: true, state: models.StateActive},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, exists := links.Get(tt.key); exists != tt.present {
+				t.Errorf("Get(%s) exists = %v, want %v", tt.key, exists, tt.present)
+			}
+			entry, exists := links.GetEntry(tt.key)
+			if !exists {
+				t.Fatalf("GetEntry(%s) should return scheduled links", tt.key)
+			}
+			if entry.State != tt.state {
+				t.Errorf("GetEntry(%s) state = %s, want %s", tt.key, entry.State, tt.state)
+			}
+		})
+	}
+}
+
+func TestLinkMap_PutRejectsInvalidSchedule(t *testing.T) {
+	links := NewLinkMap(storage.NONE, "")
+	start := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(-time.Hour)
+
+	err := links.Put(context.Background(), &models.Entry{Path: "backwards", Target: "https://foo.com", ActiveFrom: &start, ExpiresAt: &end})
+	if !errors.Is(err, ErrInvalidSchedule) {
+		t.Fatalf("expected ErrInvalidSchedule, got %v", err)
+	}
+	if _, exists := links.GetEntry("backwards"); exists {
+		t.Fatal("rejected entry should not be stored")
+	}
+}
+
+func TestLinkMap_ArchiveExpired(t *testing.T) {
+	now := time.Date(2025, 6, 10, 0, 0, 0, 0, time.UTC)
+	longAgo := now.Add(-48 * time.Hour)
+	recently := now.Add(-time.Hour)
+
+	links := NewLinkMap(storage.NONE, "", WithArchiveGracePeriod(24*time.Hour))
+	links.now = func() time.Time { return now }
+	links.Put(context.Background(), &models.Entry{Path: "old", Target: "https://old.com", ExpiresAt: &longAgo})
+	links.Put(context.Background(), &models.Entry{Path: "recent", Target: "https://recent.com", ExpiresAt: &recently})
+	links.Put(context.Background(), &models.Entry{Path: "forever", Target: "https://forever.com"})
+
+	links.archiveExpired()
+
+	if _, exists := links.GetEntry("old"); exists {
+		t.Error("link expired past the grace period should be archived")
+	}
+	if _, exists := links.GetEntry("recent"); !exists {
+		t.Error("link expired within the grace period should be kept")
+	}
+	if _, exists := links.GetEntry("forever"); !exists {
+		t.Error("unscheduled link should be kept")
+	}
+}
+
+func TestLinkMap_ArchiveExpiredTakesAliases(t *testing.T) {
+	now := time.Date(2025, 6, 10, 0, 0, 0, 0, time.UTC)
+	longAgo := now.Add(-48 * time.Hour)
+
+	links := NewLinkMap(storage.NONE, "", WithArchiveGracePeriod(24*time.Hour))
+	links.now = func() time.Time { return now }
+	for _, entry := range []*models.Entry{
+		{Path: "old", Target: "https://old.com", ExpiresAt: &longAgo},
+		{Path: "legacy", AliasOf: "old"},
+		{Path: "ancient", AliasOf: "legacy"},
+		{Path: "current", Target: "https://current.com"},
+		{Path: "now", AliasOf: "current"},
+	} {
+		if err := links.Put(context.Background(), entry); err != nil {
+			t.Fatalf("Put(%s): %v", entry.Path, err)
+		}
+	}
+
+	links.archiveExpired()
+
+	for _, path := range []string{"old", "legacy", "ancient"} {
+		if _, exists := links.GetEntry(path); exists {
+			t.Errorf("%s should be archived along with the expired link it leads to", path)
+		}
+	}
+	for _, path := range []string{"current", "now"} {
+		if _, exists := links.GetEntry(path); !exists {
+			t.Errorf("%s should be kept", path)
+		}
+	}
+}
+
+func TestLinkMap_DeleteMovesToTrash(t *testing.T) {
+	links := NewLinkMap(storage.NONE, "")
+	links.Put(context.Background(), &models.Entry{Path: "foo", Target: "https://foo.com"})
+
+	links.Delete(context.Background(), "foo")
+
+	if _, exists := links.Get("foo"); exists {
+		t.Fatal("deleted link should not resolve")
+	}
+	trashed, exists := links.GetTrash()["foo"]
+	if !exists {
+		t.Fatal("deleted link should be in the trash")
+	}
+	if trashed.Target != "https://foo.com" || trashed.DeletedAt == nil {
+		t.Errorf("unexpected trash entry %+v", trashed)
+	}
+}
+
+func TestLinkMap_Restore(t *testing.T) {
+	links := NewLinkMap(storage.NONE, "")
+	links.Put(context.Background(), &models.Entry{Path: "foo", Target: "https://foo.com"})
+	links.Put(context.Background(), &models.Entry{Path: "bar", Target: "https://bar.com"})
+	links.Delete(context.Background(), "foo")
+	links.Delete(context.Background(), "bar")
+	links.Put(context.Background(), &models.Entry{Path: "bar", Target: "https://new-bar.com"})
+	tests := []struct {
+		name    string
+		key     string
+		wantErr error
+		target  string
+	}{
+		{name: "Restores a trashed link", key: "foo", target: "https://foo.com"},
+		{name: "Fails when the link is not in the trash", key: "foo", wantErr: ErrNotInTrash, target: "https://foo.com"},
+		{name: "Fails when a live link has the same path", key: "bar", wantErr: ErrLinkExists, target: "https://new-bar.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := links.Restore(context.Background(), tt.key)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Restore(%s) error = %v, want %v", tt.key, err, tt.wantErr)
+			}
+			if target, _ := links.Get(tt.key); target != tt.target {
+				t.Errorf("Get(%s) = %s, want %s", tt.key, target, tt.target)
+			}
+		})
+	}
+}
+
+func TestLinkMap_RestoreOtherSpellingAfterRestart(t *testing.T) {
+	path := t.TempDir() + "/links"
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	links := NewLinkMap(storage.FILE, path)
+	for _, entry := range []*models.Entry{
+		{Path: "Foo", Target: "https://old-foo.com"},
+		{Path: "foo", Target: "https://foo.com"},
+	} {
+		if err := links.Put(ctx, entry); err != nil {
+			t.Fatal(err)
+		}
+		if err := links.Delete(ctx, entry.Path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := links.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	links = NewLinkMap(storage.FILE, path)
+	defer links.Close(ctx)
+	if trash := links.GetTrash(); len(trash) != 1 {
+		t.Errorf("trash = %v, want one entry for both spellings", trash)
+	}
+	restored, err := links.Restore(ctx, "FOO")
+	if err != nil {
+		t.Fatalf("Restore(FOO) error = %v", err)
+	}
+	if restored.Path != "foo" || restored.Target != "https://foo.com" {
+		t.Errorf("restored %s -> %s, want the last trashed foo", restored.Path, restored.Target)
+	}
+	if trash := links.GetTrash(); len(trash) != 0 {
+		t.Errorf("trash = %v after restoring, want it empty", trash)
+	}
+}
+
+func TestLinkMap_PurgeTrashKeepsClicksOfRecreatedLink(t *testing.T) {
+	now := time.Date(2025, 6, 10, 0, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+	links := NewLinkMap(storage.NONE, "", WithTrashRetention(24*time.Hour))
+	links.now = func() time.Time { return now.Add(-48 * time.Hour) }
+	links.Put(ctx, &models.Entry{Path: "docs", Target: "https://old-docs.com"})
+	links.RecordClick("docs")
+	links.Delete(ctx, "docs")
+
+	links.Put(ctx, &models.Entry{Path: "Docs", Target: "https://docs.com"})
+	if entry, _ := links.GetEntry("docs"); entry.Clicks != 0 {
+		t.Errorf("recreated link has %d clicks, want it to start from 0", entry.Clicks)
+	}
+	links.RecordClick("Docs")
+	links.RecordClick("Docs")
+
+	links.now = func() time.Time { return now }
+	links.purgeTrash()
+
+	if _, exists := links.GetTrash()["docs"]; exists {
+		t.Fatal("the old copy should be purged")
+	}
+	if entry, _ := links.GetEntry("docs"); entry.Clicks != 2 {
+		t.Errorf("live link has %d clicks after purging its old copy, want 2", entry.Clicks)
+	}
+}
+
+func TestLinkMap_PurgeTrash(t *testing.T) {
+	now := time.Date(2025, 6, 10, 0, 0, 0, 0, time.UTC)
+	links := NewLinkMap(storage.NONE, "", WithTrashRetention(24*time.Hour))
+	links.Put(context.Background(), &models.Entry{Path: "old", Target: "https://old.com"})
+	links.Put(context.Background(), &models.Entry{Path: "recent", Target: "https://recent.com"})
+
+	links.now = func() time.Time { return now.Add(-48 * time.Hour) }
+	links.Delete(context.Background(), "old")
+	links.now = func() time.Time { return now.Add(-time.Hour) }
+	links.Delete(context.Background(), "recent")
+	links.now = func() time.Time { return now }
+
+	links.purgeTrash()
+
+	trash := links.GetTrash()
+	if _, exists := trash["old"]; exists {
+		t.Error("link trashed past the retention period should be purged")
+	}
+	if _, exists := trash["recent"]; !exists {
+		t.Error("link trashed within the retention period should be kept")
+	}
+}
+
+func TestLinkMap_NormalizedLookup(t *testing.T) {
+	links := NewLinkMap(storage.NONE, "")
+	links.Put(context.Background(), &models.Entry{Path: "OnCall", Target: "https://oncall.com"})
+
+	for _, key := range []string{"OnCall", "oncall", "on-call", "on_call", "ON.CALL"} {
+		if target, exists := links.Get(key); !exists || target != "https://oncall.com" {
+			t.Errorf("Get(%s) = %s, %v; want https://oncall.com", key, target, exists)
+		}
+	}
+
+	if _, exists := links.GetAll()["OnCall"]; !exists {
+		t.Error("GetAll should key entries by the path as written")
+	}
+
+	links.Delete(context.Background(), "on-call")
+	if _, exists := links.GetTrash()["OnCall"]; !exists {
+		t.Error("Delete should find the link through its normalized path")
+	}
+}
+
+func TestLinkMap_PutRejectsNormalizedConflict(t *testing.T) {
+	links := NewLinkMap(storage.NONE, "")
+	links.Put(context.Background(), &models.Entry{Path: "on-call", Target: "https://oncall.com"})
+
+	err := links.Put(context.Background(), &models.Entry{Path: "OnCall", Target: "https://other.com"})
+	if !errors.Is(err, ErrPathConflict) {
+		t.Fatalf("expected ErrPathConflict, got %v", err)
+	}
+	if target, _ := links.Get("on-call"); target != "https://oncall.com" {
+		t.Errorf("conflicting put should not replace the existing link, got %s", target)
+	}
+
+	if err := links.Update(context.Background(), &models.Entry{Path: "on-call", Target: "https://new.com"}); err != nil {
+		t.Errorf("updating with the original spelling should succeed, got %v", err)
+	}
+}
+
+func TestLinkMap_NoNormalization(t *testing.T) {
+	links := NewLinkMap(storage.NONE, "", WithNormalizer(normalize.New()))
+	links.Put(context.Background(), &models.Entry{Path: "OnCall", Target: "https://oncall.com"})
+
+	if _, exists := links.Get("oncall"); exists {
+		t.Error("paths should match exactly without normalization rules")
+	}
+}
+
+func TestLinkMap_UpdateKeepsCreatedAt(t *testing.T) {
+	created := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	updated := created.Add(time.Hour)
+	links := NewLinkMap(storage.NONE, "")
+
+	links.now = func() time.Time { return created }
+	links.Put(context.Background(), &models.Entry{Path: "foo", Target: "https://foo.com"})
+	links.now = func() time.Time { return updated }
+	links.Update(context.Background(), &models.Entry{Path: "foo", Target: "https://new-foo.com"})
+
+	entry, _ := links.GetEntry("foo")
+	if entry.CreatedAt == nil || !entry.CreatedAt.Equal(created) {
+		t.Errorf("CreatedAt = %v, want %v", entry.CreatedAt, created)
+	}
+	if entry.UpdatedAt == nil || !entry.UpdatedAt.Equal(updated) {
+		t.Errorf("UpdatedAt = %v, want %v", entry.UpdatedAt, updated)
+	}
+}
+
+func TestLinkMap_RecordClick(t *testing.T) {
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	links := NewLinkMap(storage.NONE, "")
+	links.now = func() time.Time { return now }
+	links.Put(context.Background(), &models.Entry{Path: "on-call", Target: "https://oncall.com"})
+	links.Put(context.Background(), &models.Entry{Path: "unused", Target: "https://unused.com"})
+
+	links.RecordClick("on-call")
+	links.RecordClick("OnCall")
+
+	entry, _ := links.GetEntry("on-call")
+	if entry.Clicks != 2 {
+		t.Errorf("Clicks = %d, want 2", entry.Clicks)
+	}
+	if entry.LastClickedAt == nil || !entry.LastClickedAt.Equal(now) {
+		t.Errorf("LastClickedAt = %v, want %v", entry.LastClickedAt, now)
+	}
+
+	unused, _ := links.GetEntry("unused")
+	if unused.Clicks != 0 || unused.LastClickedAt != nil {
+		t.Errorf("unused link got %d clicks at %v", unused.Clicks, unused.LastClickedAt)
+	}
+}
+
+func TestLinkMap_CloseSavesPendingWrites(t *testing.T) {
+	path := t.TempDir() + "/links"
+	if err := os.WriteFile(path, []byte("foo https://foo.com\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	links := NewLinkMap(storage.FILE, path)
+	ctx := context.Background()
+	for _, entry := range []*models.Entry{
+		{Path: "bar", Target: "https://bar.com"},
+		{Path: "baz", Target: "https://baz.com"},
+	} {
+		if err := links.Put(ctx, entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := links.Delete(ctx, "foo"); err != nil {
+		t.Fatal(err)
+	}
+	links.RecordClick("bar")
+
+	if err := links.Close(ctx); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if err := links.Put(ctx, &models.Entry{Path: "late", Target: "https://late.com"}); !errors.Is(err, ErrClosed) {
+		t.Errorf("Put after Close error = %v, want %v", err, ErrClosed)
+	}
+
+	saved := storage.NewFileStorage(path)
+	defer saved.Close()
+	entries, err := saved.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for path := range entries {
+		paths = append(paths, path)
+	}
+	slices.Sort(paths)
+	if want := []string{"bar", "baz"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("saved links = %v, want %v", paths, want)
+	}
+	trash, err := saved.ReadTrash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, exists := trash["foo"]; !exists {
+		t.Errorf("foo is not in the saved trash: %v", trash)
+	}
+	clicks, err := saved.ReadClicks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if clicks["bar"].Count != 1 {
+		t.Errorf("saved clicks = %v, want one for bar", clicks)
+	}
+}
+
+// stalledStorage holds every Put until release is closed, and records whether
+// the click counts were saved and the storage closed.
+type stalledStorage struct {
+	storage.Storage
+	release     chan struct{}
+	clicksSaved bool
+	closed      bool
+}
+
+func (s *stalledStorage) Put(entry *models.Entry) {
+	<-s.release
+	s.Storage.Put(entry)
+}
+
+func (s *stalledStorage) WriteClicks(clicks map[string]models.ClickStats) {
+	s.clicksSaved = true
+	s.Storage.WriteClicks(clicks)
+}
+
+func (s *stalledStorage) Close() error {
+	s.closed = true
+	return s.Storage.Close()
+}
+
+// withStore sets the storage a LinkMap is built with.
+func withStore(store storage.Storage) Option {
+	return func(l *LinkMap) {
+		l.store = store
+	}
+}
+
+func TestLinkMap_CloseTimesOut(t *testing.T) {
+	store := &stalledStorage{Storage: storage.NewNoneStorage(), release: make(chan struct{})}
+	defer close(store.release)
+	links := NewLinkMap(storage.NONE, "", withStore(store))
+
+	if err := links.Put(context.Background(), &models.Entry{Path: "stuck", Target: "https://stuck.com"}); err != nil {
+		t.Fatal(err)
+	}
+	links.RecordClick("stuck")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := links.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Close() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if !store.clicksSaved {
+		t.Error("Close should save the click counts after giving up on pending writes")
+	}
+	if !store.closed {
+		t.Error("Close should close the storage after giving up on pending writes")
+	}
+	if err := links.Close(context.Background()); err != nil {
+		t.Errorf("second Close() error = %v", err)
+	}
+}
+
+func TestLinkMap_ReloadsOnlyOutsideChanges(t *testing.T) {
+	path := t.TempDir() + "/links"
+	if err := os.WriteFile(path, []byte("foo https://foo.com\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	links := NewLinkMap(storage.FILE, path)
+	defer links.Close(context.Background())
+
+	if err := links.Put(context.Background(), &models.Entry{Path: "bar", Target: "https://bar.com"}); err != nil {
+		t.Fatal(err)
+	}
+	links.mapLock.RLock()
+	index := links.searchIndex
+	links.mapLock.RUnlock()
+	time.Sleep(200 * time.Millisecond)
+	links.mapLock.RLock()
+	rebuilt := links.searchIndex != index
+	links.mapLock.RUnlock()
+	if rebuilt {
+		t.Error("Put should not reload the links it wrote itself")
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.WriteString("baz https://baz.com\n"); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if target, exists := links.Get("baz"); exists {
+			if target != "https://baz.com" {
+				t.Errorf("Get(baz) = %q, want https://baz.com", target)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("an outside change to the links file was not reloaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, exists := links.Get("bar"); !exists {
+		t.Error("reload lost the link made by Put")
+	}
+}
+
+// benchmarkLinkFile writes a links file with n links and returns its path.
+func benchmarkLinkFile(b *testing.B, n int) string {
+	path := b.TempDir() + "/links"
+	var sb strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&sb, "link-%d https://example.com/%d description=link%%20number%%20%d\n", i, i, i)
+	}
+	if err := os.WriteFile(path, []byte(sb.String()), 0644); err != nil {
+		b.Fatal(err)
+	}
+	return path
+}
+
+func BenchmarkLinkMap_Reload(b *testing.B) {
+	links := NewLinkMap(storage.FILE, benchmarkLinkFile(b, 100_000))
+	defer links.Close(context.Background())
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		links.reload()
+	}
+}
+
+func BenchmarkLinkMap_Put(b *testing.B) {
+	links := NewLinkMap(storage.FILE, benchmarkLinkFile(b, 100_000))
+	defer links.Close(context.Background())
+	ctx := context.Background()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		entry := &models.Entry{Path: fmt.Sprintf("new-%d", i), Target: fmt.Sprintf("https://example.com/new/%d", i)}
+		if err := links.Put(ctx, entry); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
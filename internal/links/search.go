@@ -0,0 +1,74 @@
+package links
+
+import (
+	"context"
+	"github.com/dfryer1193/golinks/internal/search"
+	"github.com/dfryer1193/golinks/models"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Search returns copies of the entries matching the query, best match first.
+// Equally good matches are ordered by popularity, so that the most used link
+// comes first.
+func (l *LinkMap) Search(ctx context.Context, query string, opts search.Options) []*models.Entry {
+	return l.search(ctx, "Search", query, opts, false)
+}
+
+// SearchActive is like Search, but only returns links that currently resolve
+// by themselves.
+func (l *LinkMap) SearchActive(ctx context.Context, query string, opts search.Options) []*models.Entry {
+	return l.search(ctx, "SearchActive", query, opts, true)
+}
+
+func (l *LinkMap) search(ctx context.Context, name string, query string, opts search.Options, activeOnly bool) []*models.Entry {
+	_, span := tracer.Start(ctx, "LinkMap."+name, trace.WithAttributes(attribute.String("golinks.query", query)))
+	defer span.End()
+
+	l.mapLock.RLock()
+	defer l.mapLock.RUnlock()
+
+	now := l.now()
+	opts.Popularity = func(path string) float64 {
+		return l.popularity(l.key(path), now)
+	}
+	if activeOnly {
+		filter := opts.Filter
+		opts.Filter = func(path string) bool {
+			entry, exists := l.m[l.key(path)]
+			return exists && entry.StateAt(now) == models.StateActive && (filter == nil || filter(path))
+		}
+	}
+
+	hits := l.searchIndex.Search(query, opts)
+	results := make([]*models.Entry, 0, len(hits))
+	for _, hit := range hits {
+		if entry, exists := l.m[l.key(hit.Value)]; exists {
+			results = append(results, l.withState(entry))
+		}
+	}
+	span.SetAttributes(attribute.Int("golinks.results", len(results)))
+	return results
+}
+
+// buildSearchIndex indexes the links of m from scratch. It does not use the
+// LinkMap's links, so callers need not hold mapLock.
+func (l *LinkMap) buildSearchIndex(m map[string]*models.Entry) *search.Index {
+	index := search.NewIndex(l.key)
+	for _, entry := range m {
+		index.Add(searchDocument(entry))
+	}
+	return index
+}
+
+// searchDocument describes an entry to the search index. Aliases are indexed
+// without a target, since theirs follows the link they point at.
+func searchDocument(entry *models.Entry) search.Document {
+	return search.Document{
+		Key:         entry.Path,
+		Path:        entry.Path,
+		Target:      entry.Target,
+		Description: entry.Description,
+		Tags:        entry.Tags,
+	}
+}
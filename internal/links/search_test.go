@@ -0,0 +1,106 @@
+package links
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dfryer1193/golinks/internal/links/storage"
+	"github.com/dfryer1193/golinks/internal/search"
+	"github.com/dfryer1193/golinks/models"
+)
+
+func TestLinkMap_SearchFollowsChanges(t *testing.T) {
+	links := NewLinkMap(storage.NONE, "")
+	paths := func(query string) []string {
+		var result []string
+		for _, entry := range links.Search(context.Background(), query, search.DefaultOptions()) {
+			result = append(result, entry.Path)
+		}
+		return result
+	}
+
+	links.Put(context.Background(), &models.Entry{Path: "dashboard", Target: "https://grafana.example.com"})
+	links.Put(context.Background(), &models.Entry{Path: "wiki", Target: "https://wiki.example.com"})
+	if got := paths("dahsboard"); !reflect.DeepEqual(got, []string{"dashboard"}) {
+		t.Errorf("after Put: got %v", got)
+	}
+
+	links.Update(context.Background(), &models.Entry{Path: "wiki", Target: "https://grafana.example.com/wiki"})
+	if got := paths("grafana"); !reflect.DeepEqual(got, []string{"wiki", "dashboard"}) {
+		t.Errorf("after Update: got %v", got)
+	}
+
+	links.Delete(context.Background(), "dashboard")
+	if got := paths("dashboard"); got != nil {
+		t.Errorf("after Delete: got %v", got)
+	}
+
+	if _, err := links.Restore(context.Background(), "dashboard"); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if got := paths("dashboard"); !reflect.DeepEqual(got, []string{"dashboard"}) {
+		t.Errorf("after Restore: got %v", got)
+	}
+
+	err := links.ReplaceAll(context.Background(), strings.NewReader("docs https://docs.example.com\n"))
+	if err != nil {
+		t.Fatalf("ReplaceAll: %v", err)
+	}
+	if got := paths("dashboard"); got != nil {
+		t.Errorf("after ReplaceAll: got %v", got)
+	}
+	if got := paths("doc"); !reflect.DeepEqual(got, []string{"docs"}) {
+		t.Errorf("after ReplaceAll: got %v", got)
+	}
+}
+
+func TestLinkMap_SearchActive(t *testing.T) {
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	expired := now.Add(-time.Hour)
+	links := NewLinkMap(storage.NONE, "")
+	links.now = func() time.Time { return expired.Add(-time.Hour) }
+	links.Put(context.Background(), &models.Entry{Path: "dash-retired", Target: "https://old.example.com", ExpiresAt: &expired})
+	links.now = func() time.Time { return now }
+	links.Put(context.Background(), &models.Entry{Path: "dashboard", Target: "https://grafana.example.com"})
+
+	if got := links.Search(context.Background(), "dash", search.DefaultOptions()); len(got) != 2 {
+		t.Errorf("Search returned %d links, want 2", len(got))
+	}
+	got := links.SearchActive(context.Background(), "dash", search.DefaultOptions())
+	if len(got) != 1 || got[0].Path != "dashboard" {
+		t.Errorf("SearchActive returned %v, want only dashboard", got)
+	}
+}
+
+func TestLinkMap_SearchPrefersPopularLinks(t *testing.T) {
+	start := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	links := NewLinkMap(storage.NONE, "", WithPopularityHalfLife(24*time.Hour))
+	links.now = func() time.Time { return start }
+	links.Put(context.Background(), &models.Entry{Path: "kube-docs", Target: "https://docs.example.com"})
+	links.Put(context.Background(), &models.Entry{Path: "kube-prod", Target: "https://prod.example.com"})
+	paths := func() []string {
+		var result []string
+		for _, entry := range links.Search(context.Background(), "kube", search.DefaultOptions()) {
+			result = append(result, entry.Path)
+		}
+		return result
+	}
+
+	for range 8 {
+		links.RecordClick("kube-prod")
+	}
+	if got := paths(); !reflect.DeepEqual(got, []string{"kube-prod", "kube-docs"}) {
+		t.Errorf("got %v, want the clicked link first", got)
+	}
+
+	// Four half-lives later the old clicks are worth less than a fresh one.
+	links.now = func() time.Time { return start.Add(4 * 24 * time.Hour) }
+	links.RecordClick("kube-docs")
+	links.RecordClick("kube-docs")
+	if got := paths(); !reflect.DeepEqual(got, []string{"kube-docs", "kube-prod"}) {
+		t.Errorf("got %v, want the recently clicked link first", got)
+	}
+}
@@ -1,18 +1,33 @@
 package storage
 
 import (
+	"fmt"
+	"github.com/dfryer1193/golinks/models"
 	"github.com/rs/zerolog/log"
 	"io"
 	"strings"
 )
 
 type Storage interface {
-	Read() (map[string]string, error)
-	Put(key string, target string)
+	Read() (map[string]*models.Entry, error)
+	Put(entry *models.Entry)
 	Delete(key string)
-	Update(key string, target string)
+	Update(entry *models.Entry)
+	Archive(entry *models.Entry)
+	ReadTrash() (map[string]*models.Entry, error)
+	Trash(entry *models.Entry)
+	Restore(entry *models.Entry)
+	Purge(key string)
+	ReadClicks() (map[string]models.ClickStats, error)
+	WriteClicks(clicks map[string]models.ClickStats)
 	GetReloadChannel() <-chan bool
-	ReplaceConfig(reader io.Reader) (map[string]string, error)
+	ReplaceConfig(reader io.Reader) (map[string]*models.Entry, error)
+	// Check returns an error if the storage can no longer be read or watched
+	// for changes.
+	Check() error
+	// Close stops watching for changes. Writes must not be made after it is
+	// called.
+	Close() error
 }
 
 type StorageType int
@@ -39,8 +54,17 @@ func FromString(s string) StorageType {
 	return FILE
 }
 
-type ParseError struct{}
+// ParseError is returned when a line of a links file is malformed. Line is the
+// number of that line, starting at 1, and Err says what is wrong with it.
+type ParseError struct {
+	Line int
+	Err  error
+}
 
 func (e *ParseError) Error() string {
-	return "Failed to parse config"
+	return fmt.Sprintf("Failed to parse config: line %d: %v", e.Line, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
 }
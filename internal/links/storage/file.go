@@ -2,6 +2,7 @@ package storage
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -9,7 +10,9 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/dfryer1193/golinks/models"
 	"github.com/fsnotify/fsnotify"
 	"github.com/rs/zerolog/log"
 )
@@ -19,9 +22,41 @@ type FileStorage struct {
 	fileLock      *sync.RWMutex
 	watcher       *fsnotify.Watcher
 	reloadChannel chan bool
+	watchLock     *sync.Mutex
+	// watchErr is the last error of the file watcher, if it is not running.
+	watchErr error
+	// closing is closed to stop the file watcher, and watchDone once it has.
+	closing   chan struct{}
+	watchDone chan struct{}
+	// known is the version of the config as this storage found it, or last
+	// read or wrote it. Changes that leave the config at this version were made by the
+	// storage itself, and are not signalled for reload. It is guarded by
+	// fileLock.
+	known fileVersion
+	// trashKey returns the key a path is stored under in the trash.
+	trashKey func(path string) string
 }
 
-func NewFileStorage(configPath string) *FileStorage {
+// FileOption configures optional FileStorage behaviour.
+type FileOption func(*FileStorage)
+
+// WithTrashKey sets the key paths are stored under in the trash, so that
+// trashing one spelling of a link replaces another spelling with the same key.
+// By default, paths are their own key.
+func WithTrashKey(key func(path string) string) FileOption {
+	return func(f *FileStorage) {
+		f.trashKey = key
+	}
+}
+
+// fileVersion tells versions of a file apart by their size and modification
+// time.
+type fileVersion struct {
+	size    int64
+	modTime time.Time
+}
+
+func NewFileStorage(configPath string, opts ...FileOption) *FileStorage {
 	path := findConfig(configPath)
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
@@ -33,6 +68,23 @@ func NewFileStorage(configPath string) *FileStorage {
 		fileLock:      &sync.RWMutex{},
 		watcher:       watcher,
 		reloadChannel: make(chan bool),
+		watchLock:     &sync.Mutex{},
+		closing:       make(chan struct{}),
+		watchDone:     make(chan struct{}),
+		trashKey:      func(path string) string { return path },
+	}
+	for _, opt := range opts {
+		opt(storage)
+	}
+
+	storage.known = storage.configVersion()
+
+	// Register the watch before returning so that writes made right after
+	// construction are not missed.
+	err = watcher.Add(filepath.Dir(path))
+	if err != nil {
+		log.Err(err).Msg("Failed to add watcher on config dir. Config will not live reload")
+		storage.setWatchErr(fmt.Errorf("watching config dir: %w", err))
 	}
 
 	go storage.watchConfig()
@@ -41,33 +93,112 @@ func NewFileStorage(configPath string) *FileStorage {
 }
 
 func (f *FileStorage) watchConfig() {
-	dir := filepath.Dir(f.configPath)
+	defer close(f.watchDone)
+	defer close(f.reloadChannel)
+
 	name := filepath.Base(f.configPath)
-	err := f.watcher.Add(dir)
-	if err != nil {
-		log.Err(err).Msg("Failed to add watcher on config dir. Config will not live reload")
-	}
 	for {
 		select {
+		case <-f.closing:
+			return
 		case event, ok := <-f.watcher.Events:
 			if !ok {
+				f.setWatchErr(errors.New("file watcher stopped"))
 				return
 			}
 			if filepath.Base(event.Name) == name && (event.Has(fsnotify.Write) || event.Has(fsnotify.Create)) {
-				f.reloadChannel <- true
+				if f.isOwnChange() {
+					continue
+				}
+				select {
+				case f.reloadChannel <- true:
+				case <-f.closing:
+					return
+				}
 			}
 		case err, ok := <-f.watcher.Errors:
 			if !ok {
+				f.setWatchErr(errors.New("file watcher stopped"))
 				return
 			}
 			if err != nil {
 				log.Err(err).Msg("File watch error received")
+				f.setWatchErr(fmt.Errorf("watching config: %w", err))
 			}
-			fmt.Println(err)
 		}
 	}
 }
 
+// Close stops watching the config for changes, which closes the reload
+// channel, and waits for any write in progress to finish. It must only be
+// called once.
+func (f *FileStorage) Close() error {
+	close(f.closing)
+	<-f.watchDone
+	err := f.watcher.Close()
+
+	f.fileLock.Lock()
+	defer f.fileLock.Unlock()
+	return err
+}
+
+func (f *FileStorage) setWatchErr(err error) {
+	f.watchLock.Lock()
+	defer f.watchLock.Unlock()
+	f.watchErr = err
+}
+
+// Check returns an error if the link config cannot be read or the watcher that
+// reloads it has failed. A watcher error is sticky, since changes may have been
+// missed since.
+func (f *FileStorage) Check() error {
+	f.watchLock.Lock()
+	watchErr := f.watchErr
+	f.watchLock.Unlock()
+	if watchErr != nil {
+		return watchErr
+	}
+
+	f.fileLock.RLock()
+	defer f.fileLock.RUnlock()
+	file, err := openFile(f.configPath)
+	if err != nil {
+		return fmt.Errorf("reading config: %w", err)
+	}
+	return file.Close()
+}
+
+// configVersion returns the current version of the config, or the zero
+// version if it cannot be read. Callers must hold fileLock.
+func (f *FileStorage) configVersion() fileVersion {
+	info, err := os.Stat(f.configPath)
+	if err != nil {
+		return fileVersion{}
+	}
+	return fileVersion{size: info.Size(), modTime: info.ModTime()}
+}
+
+// recordWrite remembers the version of the config a write left behind, given
+// the version from before the write. If the config had been changed by
+// someone else since the storage last saw it, nothing is remembered, so that
+// their change is still signalled. Callers must hold fileLock.
+func (f *FileStorage) recordWrite(before fileVersion) {
+	if before != f.known {
+		f.known = fileVersion{}
+		return
+	}
+	f.known = f.configVersion()
+}
+
+// isOwnChange reports whether the config is still at the version the storage
+// last read or wrote, in which case a change event for it was caused by the
+// storage itself.
+func (f *FileStorage) isOwnChange() bool {
+	f.fileLock.RLock()
+	defer f.fileLock.RUnlock()
+	return f.known != fileVersion{} && f.configVersion() == f.known
+}
+
 func getHomeDir() string {
 	currentUser, err := user.Current()
 	if err != nil {
@@ -121,45 +252,77 @@ func openFile(path string) (*os.File, error) {
 	return file, nil
 }
 
-func (f *FileStorage) Read() (map[string]string, error) {
+func (f *FileStorage) Read() (map[string]*models.Entry, error) {
+	f.fileLock.Lock()
+	defer f.fileLock.Unlock()
+
+	// The version is taken before reading, so that a change made while the
+	// file is read is still signalled.
+	version := f.configVersion()
 	filePtr, err := openFile(f.configPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open file %s for reading", f.configPath)
 	}
 	defer filePtr.Close()
 
-	return parseLinksFile(filePtr)
+	entries, err := parseLinksFile(filePtr)
+	if err != nil {
+		return nil, err
+	}
+	f.known = version
+	return entries, nil
 }
 
 // Put appends a new entry to the link config. If the entry already exists, it will be duplicated in the file.
-func (f *FileStorage) Put(key string, target string) {
+func (f *FileStorage) Put(entry *models.Entry) {
 	f.fileLock.Lock()
 	defer f.fileLock.Unlock()
 
-	file, err := os.OpenFile(f.configPath, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
-	defer file.Close()
-	if err != nil {
+	before := f.configVersion()
+	defer f.recordWrite(before)
+	if err := appendLine(f.configPath, FormatEntry(entry)); err != nil {
 		log.
 			Error().
 			Err(err).
 			Str("file path", f.configPath).
-			Msg("Failed to open file for writing")
-		return
+			Str("key", entry.Path).
+			Str("target", entry.Target).
+			Msg("Failed to write to file")
 	}
+}
 
-	if _, err := file.WriteString(key + " " + target + "\n"); err != nil {
+// Archive moves an entry out of the link config and appends it to the archive
+// file that lives alongside it.
+func (f *FileStorage) Archive(entry *models.Entry) {
+	f.fileLock.Lock()
+	err := appendLine(f.getArchiveConfigFilepath(), FormatEntry(entry))
+	f.fileLock.Unlock()
+	if err != nil {
 		log.
 			Error().
 			Err(err).
-			Str("file path", f.configPath).
-			Str("key", key).
-			Str("target", target).
-			Msg("Failed to write to file")
+			Str("file path", f.getArchiveConfigFilepath()).
+			Str("key", entry.Path).
+			Msg("Failed to write archived entry, leaving it in place")
+		return
+	}
+
+	f.Delete(entry.Path)
+}
+
+func appendLine(path string, line string) error {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
+	if err != nil {
+		return err
 	}
+	defer file.Close()
+
+	_, err = file.WriteString(line + "\n")
+	return err
 }
 
 func (f *FileStorage) Delete(key string) {
-	changed, err := f.updateEntry(key, "")
+	changed, err := f.updateEntry(key, nil)
 	if err != nil {
 		log.
 			Error().
@@ -180,14 +343,14 @@ func (f *FileStorage) Delete(key string) {
 	}
 }
 
-func (f *FileStorage) Update(key string, target string) {
-	changed, err := f.updateEntry(key, target)
+func (f *FileStorage) Update(entry *models.Entry) {
+	changed, err := f.updateEntry(entry.Path, entry)
 	if err != nil {
 		log.
 			Error().
 			Err(err).
-			Str("key", key).
-			Str("target", target).
+			Str("key", entry.Path).
+			Str("target", entry.Target).
 			Msg("Failed to update key")
 	}
 
@@ -197,14 +360,14 @@ func (f *FileStorage) Update(key string, target string) {
 			log.
 				Error().
 				Err(err).
-				Str("key", key).
-				Str("target", target).
+				Str("key", entry.Path).
+				Str("target", entry.Target).
 				Msg("Failed to replace config file in place after update")
 		}
 	}
 }
 
-func (f *FileStorage) ReplaceConfig(reader io.Reader) (map[string]string, error) {
+func (f *FileStorage) ReplaceConfig(reader io.Reader) (map[string]*models.Entry, error) {
 	err := f.backupAndReplace(reader)
 	if err != nil {
 		return nil, err
@@ -216,7 +379,9 @@ func (f *FileStorage) ReplaceConfig(reader io.Reader) (map[string]string, error)
 	return parseLinksFile(file)
 }
 
-func (f *FileStorage) updateEntry(key string, target string) (bool, error) {
+// updateEntry writes a scratch copy of the config with every line for key
+// replaced by entry. A nil entry removes the key instead.
+func (f *FileStorage) updateEntry(key string, entry *models.Entry) (bool, error) {
 	f.fileLock.Lock()
 	defer f.fileLock.Unlock()
 
@@ -239,12 +404,12 @@ func (f *FileStorage) updateEntry(key string, target string) (bool, error) {
 
 		// Path exists somewhere in the file
 		if strings.HasPrefix(txt, key+" ") {
-			if target == "" {
+			if entry == nil {
 				changed = true
 				continue
 			}
 
-			if _, err := newFile.WriteString(key + " " + target + "\n"); err != nil {
+			if _, err := newFile.WriteString(FormatEntry(entry) + "\n"); err != nil {
 				return false, err
 			}
 			changed = true
@@ -266,6 +431,10 @@ func (f *FileStorage) getScratchConfigFilepath() string {
 	return f.configPath + "~"
 }
 
+func (f *FileStorage) getArchiveConfigFilepath() string {
+	return f.configPath + ".archive"
+}
+
 func (f *FileStorage) getBackupConfigFilepath() string {
 	return f.configPath + ".bak"
 }
@@ -273,6 +442,9 @@ func (f *FileStorage) getBackupConfigFilepath() string {
 func (f *FileStorage) replaceConfigInPlace() error {
 	f.fileLock.Lock()
 	defer f.fileLock.Unlock()
+
+	before := f.configVersion()
+	defer f.recordWrite(before)
 	err := os.Rename(f.configPath, f.getBackupConfigFilepath())
 	if err != nil {
 		return err
@@ -291,6 +463,9 @@ func (f *FileStorage) replaceConfigInPlace() error {
 func (f *FileStorage) backupAndReplace(reader io.Reader) error {
 	f.fileLock.Lock()
 	defer f.fileLock.Unlock()
+
+	before := f.configVersion()
+	defer f.recordWrite(before)
 	err := os.Rename(f.configPath, f.getBackupConfigFilepath())
 	if err != nil {
 		return fmt.Errorf("failed to backup config file: %w", err)
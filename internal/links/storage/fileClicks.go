@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dfryer1193/golinks/models"
+	"github.com/rs/zerolog/log"
+)
+
+// ReadClicks returns the click counts saved alongside the link config, keyed by
+// path. A missing clicks file is treated as no clicks at all.
+func (f *FileStorage) ReadClicks() (map[string]models.ClickStats, error) {
+	f.fileLock.RLock()
+	defer f.fileLock.RUnlock()
+
+	file, err := os.Open(f.getClicksConfigFilepath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return make(map[string]models.ClickStats), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	clicks := make(map[string]models.ClickStats)
+	sc := bufio.NewScanner(file)
+	lineNum := 0
+	for sc.Scan() {
+		lineNum++
+		path, stats, err := parseClicksLine(sc.Text())
+		if err != nil {
+			log.Warn().Err(err).Int("line", lineNum).Msg("Skipping malformed click count")
+			continue
+		}
+		if path != "" {
+			clicks[path] = stats
+		}
+	}
+
+	return clicks, sc.Err()
+}
+
+// WriteClicks replaces the saved click counts. Counts are kept out of the link
+// config so that recording a click never triggers a reload.
+func (f *FileStorage) WriteClicks(clicks map[string]models.ClickStats) {
+	f.fileLock.Lock()
+	defer f.fileLock.Unlock()
+
+	if err := f.writeClicksFile(clicks); err != nil {
+		log.
+			Error().
+			Err(err).
+			Str("file path", f.getClicksConfigFilepath()).
+			Msg("Failed to write click counts")
+	}
+}
+
+func (f *FileStorage) getClicksConfigFilepath() string {
+	return f.configPath + ".clicks"
+}
+
+func (f *FileStorage) writeClicksFile(clicks map[string]models.ClickStats) error {
+	scratchPath := f.getClicksConfigFilepath() + "~"
+	scratch, err := os.Create(scratchPath)
+	if err != nil {
+		return err
+	}
+	defer scratch.Close()
+
+	paths := make([]string, 0, len(clicks))
+	for path := range clicks {
+		paths = append(paths, path)
+	}
+	slices.Sort(paths)
+
+	for _, path := range paths {
+		stats := clicks[path]
+		line := fmt.Sprintf(
+			"%s %d %s %s\n",
+			path,
+			stats.Count,
+			stats.LastClickedAt.UTC().Format(time.RFC3339),
+			strconv.FormatFloat(stats.Popularity, 'g', -1, 64),
+		)
+		if _, err := scratch.WriteString(line); err != nil {
+			return err
+		}
+	}
+	if err := scratch.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(scratchPath, f.getClicksConfigFilepath())
+}
+
+// parseClicksLine parses a line of the form "path count lastClickedAt
+// popularity". Blank lines yield an empty path.
+func parseClicksLine(line string) (string, models.ClickStats, error) {
+	parts := strings.Fields(line)
+	if len(parts) == 0 {
+		return "", models.ClickStats{}, nil
+	}
+	if len(parts) != 4 {
+		return "", models.ClickStats{}, fmt.Errorf("expected 4 fields, got %d", len(parts))
+	}
+
+	count, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return "", models.ClickStats{}, err
+	}
+	lastClickedAt, err := time.Parse(time.RFC3339, parts[2])
+	if err != nil {
+		return "", models.ClickStats{}, err
+	}
+
+	popularity, err := strconv.ParseFloat(parts[3], 64)
+	if err != nil {
+		return "", models.ClickStats{}, err
+	}
+
+	return parts[0], models.ClickStats{Count: count, LastClickedAt: lastClickedAt, Popularity: popularity}, nil
+}
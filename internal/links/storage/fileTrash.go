@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/dfryer1193/golinks/models"
+	"github.com/rs/zerolog/log"
+)
+
+// ReadTrash returns the entries currently held in the trash file, by path. A
+// missing trash file is treated as an empty trash.
+func (f *FileStorage) ReadTrash() (map[string]*models.Entry, error) {
+	f.fileLock.RLock()
+	defer f.fileLock.RUnlock()
+
+	trash, err := f.readTrashFile()
+	if err != nil {
+		return nil, err
+	}
+	byPath := make(map[string]*models.Entry, len(trash))
+	for _, entry := range trash {
+		byPath[entry.Path] = entry
+	}
+	return byPath, nil
+}
+
+// Trash moves an entry from the link config into the trash file. If the path,
+// or another spelling with the same trash key, is already in the trash, the
+// older copy is replaced.
+func (f *FileStorage) Trash(entry *models.Entry) {
+	err := f.modifyTrash(func(trash map[string]*models.Entry) {
+		trash[f.trashKey(entry.Path)] = entry
+	})
+	if err != nil {
+		log.
+			Error().
+			Err(err).
+			Str("key", entry.Path).
+			Msg("Failed to write entry to trash, leaving it in place")
+		return
+	}
+
+	f.Delete(entry.Path)
+}
+
+// Restore removes an entry from the trash file and appends it to the link
+// config.
+func (f *FileStorage) Restore(entry *models.Entry) {
+	err := f.modifyTrash(func(trash map[string]*models.Entry) {
+		delete(trash, f.trashKey(entry.Path))
+	})
+	if err != nil {
+		log.
+			Error().
+			Err(err).
+			Str("key", entry.Path).
+			Msg("Failed to remove restored entry from trash")
+	}
+
+	f.Put(entry)
+}
+
+// Purge permanently removes an entry from the trash file.
+func (f *FileStorage) Purge(key string) {
+	err := f.modifyTrash(func(trash map[string]*models.Entry) {
+		delete(trash, f.trashKey(key))
+	})
+	if err != nil {
+		log.
+			Error().
+			Err(err).
+			Str("key", key).
+			Msg("Failed to purge entry from trash")
+	}
+}
+
+func (f *FileStorage) getTrashConfigFilepath() string {
+	return f.configPath + ".trash"
+}
+
+// readTrashFile returns the entries in the trash file by trash key. If several
+// entries share a key, as in files written before the trash was keyed, the one
+// deleted last is kept.
+func (f *FileStorage) readTrashFile() (map[string]*models.Entry, error) {
+	file, err := os.Open(f.getTrashConfigFilepath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return make(map[string]*models.Entry), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	lines, err := ParseLines(file)
+	if err != nil {
+		return nil, err
+	}
+	trash := make(map[string]*models.Entry, len(lines))
+	for _, line := range lines {
+		key := f.trashKey(line.Entry.Path)
+		if existing, exists := trash[key]; exists && deletedAfter(existing, line.Entry) {
+			continue
+		}
+		trash[key] = line.Entry
+	}
+	return trash, nil
+}
+
+// deletedAfter reports whether a was deleted after b.
+func deletedAfter(a, b *models.Entry) bool {
+	return a.DeletedAt != nil && (b.DeletedAt == nil || a.DeletedAt.After(*b.DeletedAt))
+}
+
+// modifyTrash applies fn to the contents of the trash file and writes the
+// result back through a scratch file so that a failed write never truncates
+// the trash.
+func (f *FileStorage) modifyTrash(fn func(trash map[string]*models.Entry)) error {
+	f.fileLock.Lock()
+	defer f.fileLock.Unlock()
+
+	trash, err := f.readTrashFile()
+	if err != nil {
+		return err
+	}
+	fn(trash)
+
+	scratchPath := f.getTrashConfigFilepath() + "~"
+	scratch, err := os.Create(scratchPath)
+	if err != nil {
+		return err
+	}
+	defer scratch.Close()
+
+	entries := make([]*models.Entry, 0, len(trash))
+	for _, entry := range trash {
+		entries = append(entries, entry)
+	}
+	slices.SortFunc(entries, func(a, b *models.Entry) int { return strings.Compare(a.Path, b.Path) })
+
+	for _, entry := range entries {
+		if _, err := scratch.WriteString(FormatEntry(entry) + "\n"); err != nil {
+			return err
+		}
+	}
+	if err := scratch.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(scratchPath, f.getTrashConfigFilepath())
+}
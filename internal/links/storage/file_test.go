@@ -1,10 +1,11 @@
 package storage
 
 import (
+	"github.com/dfryer1193/golinks/models"
 	"github.com/rs/zerolog/log"
-	"net/url"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -13,16 +14,8 @@ const TEST_DIR = "./test"
 const TEST_FILE = "test.links"
 
 func createTestFile() {
-	fileInfo, err := os.Stat(TEST_DIR)
-	if os.IsNotExist(err) {
-		err := os.Mkdir(TEST_DIR, 0777)
-		if err != nil {
-			log.Fatal().Err(err).Msg("Failed to create test dir")
-		}
-	}
-
-	if !fileInfo.IsDir() {
-		log.Fatal().Msg("Test dir is not a directory")
+	if err := os.MkdirAll(TEST_DIR, 0777); err != nil {
+		log.Fatal().Err(err).Msg("Failed to create test dir")
 	}
 
 	file, err := os.Create(TEST_DIR + "/" + TEST_FILE)
@@ -40,6 +33,7 @@ func cleanup() {
 	if err != nil {
 		log.Fatal().Err(err).Msg("Failed to remove test file")
 	}
+	os.Remove(TEST_DIR + "/" + TEST_FILE + ".archive")
 }
 
 func TestFileStorage_Delete(t *testing.T) {
@@ -57,7 +51,7 @@ func TestFileStorage_Delete(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			f.Delete(tt.key)
-			entries := f.Read()
+			entries, _ := f.Read()
 			_, exists := entries[tt.key]
 			if exists != tt.present {
 				log.Fatal().Msgf("Expected entry %s to not be present", tt.key)
@@ -80,11 +74,11 @@ func TestFileStorage_Put(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			f.Put(tt.key, tt.target)
-			entries := f.Read()
-			actual := entries[tt.key]
+			f.Put(&models.Entry{Path: tt.key, Target: tt.target})
+			entries, _ := f.Read()
+			actual := targetOf(entries, tt.key)
 			if actual != tt.target {
-				log.Fatal().Msgf("Expected entry %s to contain %s, got %s instead.", entries[tt.key], tt.target, actual)
+				log.Fatal().Msgf("Expected entry %s to contain %s, got %s instead.", tt.key, tt.target, actual)
 			}
 		})
 	}
@@ -109,16 +103,16 @@ func TestFileStorage_Read(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			switch tt.operation {
 			case "put":
-				f.Put(tt.key, tt.target)
+				f.Put(&models.Entry{Path: tt.key, Target: tt.target})
 			case "update":
-				f.Update(tt.key, tt.target)
+				f.Update(&models.Entry{Path: tt.key, Target: tt.target})
 			case "delete":
 				f.Delete(tt.key)
 			}
 
-			actual := f.Read()
-			if actual[tt.key] != tt.target {
-				log.Fatal().Msgf("Expected entry %s to contain %s, got %s instead.", actual, tt.target, actual)
+			actual, _ := f.Read()
+			if targetOf(actual, tt.key) != tt.target {
+				log.Fatal().Msgf("Expected entry %s to contain %s, got %s instead.", tt.key, tt.target, targetOf(actual, tt.key))
 			}
 		})
 	}
@@ -138,11 +132,11 @@ func TestFileStorage_Update(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			f.Put(tt.key, tt.target)
-			entries := f.Read()
-			actual := entries[tt.key]
+			f.Put(&models.Entry{Path: tt.key, Target: tt.target})
+			entries, _ := f.Read()
+			actual := targetOf(entries, tt.key)
 			if actual != tt.target {
-				log.Fatal().Msgf("Expected entry %s to contain %s, got %s instead.", entries[tt.key], tt.target, actual)
+				log.Fatal().Msgf("Expected entry %s to contain %s, got %s instead.", tt.key, tt.target, actual)
 			}
 		})
 	}
@@ -160,23 +154,28 @@ func TestFileStorage_ReloadSignaling(t *testing.T) {
 		target       string
 		expectReload bool
 	}{
-		{name: "Sends reload signal after new entry", operation: "put", key: "baz", target: "https://baz.com", expectReload: true},
+		{name: "Does not send reload signal after new entry", operation: "put", key: "baz", target: "https://baz.com", expectReload: false},
 		{name: "Does not send reload signal after read", operation: "read", expectReload: false},
-		{name: "Sends reload signal after updating target", operation: "update", key: "foo", target: "https://foo.com", expectReload: true},
-		{name: "Sends reload signal when deleting target", operation: "delete", key: "foo", expectReload: true},
-		{name: "Does not send reload signal when delete does not change the file", operation: "delete", key: "foo", expectReload: false},
+		{name: "Does not send reload signal after updating target", operation: "update", key: "foo", target: "https://foo.com", expectReload: false},
+		{name: "Sends reload signal after an outside change", operation: "append", key: "qux", target: "https://qux.com", expectReload: true},
+		{name: "Does not send reload signal after reading the outside change", operation: "read", expectReload: false},
+		{name: "Does not send reload signal when deleting target", operation: "delete", key: "foo", expectReload: false},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			switch tt.operation {
 			case "put":
-				f.Put(tt.key, tt.target)
+				f.Put(&models.Entry{Path: tt.key, Target: tt.target})
 			case "update":
-				f.Update(tt.key, tt.target)
+				f.Update(&models.Entry{Path: tt.key, Target: tt.target})
 			case "delete":
 				f.Delete(tt.key)
 			case "read":
 				f.Read()
+			case "append":
+				if err := appendLine(f.configPath, tt.key+" "+tt.target); err != nil {
+					t.Fatal(err)
+				}
 			}
 
 			select {
@@ -184,7 +183,7 @@ func TestFileStorage_ReloadSignaling(t *testing.T) {
 				if reload != tt.expectReload {
 					log.Fatal().Msg("Got unexpected reload signal")
 				}
-			case <-time.After(time.Millisecond * 1000):
+			case <-time.After(time.Millisecond * 200):
 				if tt.expectReload {
 					log.Fatal().Msg("Did not receive expected reload signal")
 				}
@@ -194,28 +193,293 @@ func TestFileStorage_ReloadSignaling(t *testing.T) {
 	cleanup()
 }
 
+func TestFileStorage_Archive(t *testing.T) {
+	createTestFile()
+	f := NewFileStorage(TEST_DIR + "/" + TEST_FILE)
+	expiry := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	f.Archive(&models.Entry{Path: "foo", Target: "https://test.com", ExpiresAt: &expiry})
+
+	entries, _ := f.Read()
+	if _, exists := entries["foo"]; exists {
+		t.Error("Expected archived entry to be removed from the config")
+	}
+
+	archive, err := os.ReadFile(f.getArchiveConfigFilepath())
+	if err != nil {
+		t.Fatalf("Failed to read archive file: %v", err)
+	}
+	expected := "foo https://test.com expiresAt=2025-06-01T00:00:00Z\n"
+	if string(archive) != expected {
+		t.Errorf("archive = %q, want %q", archive, expected)
+	}
+	cleanup()
+}
+
+func TestFileStorage_TrashAndRestore(t *testing.T) {
+	createTestFile()
+	f := NewFileStorage(TEST_DIR + "/" + TEST_FILE)
+	deletedAt := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	entry := &models.Entry{Path: "foo", Target: "https://test.com"}
+
+	f.Trash(&models.Entry{Path: "foo", Target: "https://test.com", DeletedAt: &deletedAt})
+
+	entries, _ := f.Read()
+	if _, exists := entries["foo"]; exists {
+		t.Error("Expected trashed entry to be removed from the config")
+	}
+	trash, err := f.ReadTrash()
+	if err != nil {
+		t.Fatalf("ReadTrash() error = %v", err)
+	}
+	if trashed, exists := trash["foo"]; !exists || !trashed.DeletedAt.Equal(deletedAt) {
+		t.Errorf("Expected trashed entry in the trash file, got %v", trash)
+	}
+
+	f.Restore(entry)
+
+	entries, _ = f.Read()
+	if targetOf(entries, "foo") != "https://test.com" {
+		t.Error("Expected restored entry to be back in the config")
+	}
+	trash, _ = f.ReadTrash()
+	if _, exists := trash["foo"]; exists {
+		t.Error("Expected restored entry to be removed from the trash")
+	}
+
+	os.Remove(f.getTrashConfigFilepath())
+	cleanup()
+}
+
+func TestFileStorage_TrashKey(t *testing.T) {
+	createTestFile()
+	f := NewFileStorage(TEST_DIR+"/"+TEST_FILE, WithTrashKey(strings.ToLower))
+	first := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	second := first.Add(time.Hour)
+
+	f.Trash(&models.Entry{Path: "Foo", Target: "https://old.com", DeletedAt: &first})
+	f.Trash(&models.Entry{Path: "foo", Target: "https://test.com", DeletedAt: &second})
+
+	trash, err := f.ReadTrash()
+	if err != nil {
+		t.Fatalf("ReadTrash() error = %v", err)
+	}
+	if len(trash) != 1 || targetOf(trash, "foo") != "https://test.com" {
+		t.Errorf("trash = %v, want only the last trashed foo", trash)
+	}
+
+	f.Restore(&models.Entry{Path: "FOO", Target: "https://test.com"})
+	trash, _ = f.ReadTrash()
+	if len(trash) != 0 {
+		t.Errorf("trash = %v after restoring FOO, want it empty", trash)
+	}
+
+	os.Remove(f.getTrashConfigFilepath())
+	cleanup()
+}
+
+func targetOf(entries map[string]*models.Entry, key string) string {
+	if entry, exists := entries[key]; exists {
+		return entry.Target
+	}
+	return ""
+}
+
 func Test_parseLine(t *testing.T) {
+	activeFrom := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	expiresAt := time.Date(2025, 6, 4, 12, 30, 0, 0, time.UTC)
 	type args struct {
 		line    string
 		lineNum int
 	}
 	tests := []struct {
-		name  string
-		args  args
-		want  string
-		want1 *url.URL
+		name    string
+		args    args
+		want    *models.Entry
+		wantErr bool
 	}{
-		// TODO: Add test cases.
+		{
+			name: "Parses a path and target",
+			args: args{line: "foo https://foo.com/bar", lineNum: 1},
+			want: &models.Entry{Path: "foo", Target: "https://foo.com/bar"},
+		},
+		{
+			name: "Skips blank lines",
+			args: args{line: "   ", lineNum: 2},
+			want: nil,
+		},
+		{
+			name: "Parses schedule attributes",
+			args: args{line: "offsite https://offsite.com activeFrom=2025-06-01T00:00:00Z expiresAt=2025-06-04T12:30:00Z", lineNum: 3},
+			want: &models.Entry{Path: "offsite", Target: "https://offsite.com", ActiveFrom: &activeFrom, ExpiresAt: &expiresAt},
+		},
+		{
+			name: "Ignores unknown attributes",
+			args: args{line: "foo https://foo.com color=blue", lineNum: 4},
+			want: &models.Entry{Path: "foo", Target: "https://foo.com"},
+		},
+		{
+			name: "Parses an alias",
+			args: args{line: "k8s - aliasOf=kubernetes", lineNum: 5},
+			want: &models.Entry{Path: "k8s", AliasOf: "kubernetes"},
+		},
+		{
+			name: "Parses descriptive attributes",
+			args: args{line: "docs https://docs.com description=Team%20docs owner=platform tags=eng%2Cdocs createdAt=2025-06-01T00:00:00Z", lineNum: 5},
+			want: &models.Entry{Path: "docs", Target: "https://docs.com", Description: "Team docs", Owner: "platform", Tags: []string{"eng", "docs"}, CreatedAt: &activeFrom},
+		},
+		{
+			name: "Parses a redirect mode",
+			args: args{line: "docs https://docs.com redirect=308", lineNum: 5},
+			want: &models.Entry{Path: "docs", Target: "https://docs.com", Redirect: models.RedirectPermanent},
+		},
+		{
+			name:    "Rejects a line without a target",
+			args:    args{line: "foo", lineNum: 6},
+			wantErr: true,
+		},
+		{
+			name:    "Rejects a malformed attribute",
+			args:    args{line: "foo https://foo.com expiresAt", lineNum: 7},
+			wantErr: true,
+		},
+		{
+			name:    "Rejects a malformed timestamp",
+			args:    args{line: "foo https://foo.com expiresAt=tomorrow", lineNum: 8},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, got1 := parseLine(tt.args.line, tt.args.lineNum)
-			if got != tt.want {
+			got, err := parseLine(tt.args.line, tt.args.lineNum)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseLine() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("parseLine() got = %v, want %v", got, tt.want)
 			}
-			if !reflect.DeepEqual(got1, tt.want1) {
-				t.Errorf("parseLine() got1 = %v, want %v", got1, tt.want1)
+		})
+	}
+}
+
+func TestFormatEntry(t *testing.T) {
+	expiresAt := time.Date(2025, 6, 4, 12, 30, 0, 0, time.UTC)
+	entry := &models.Entry{Path: "offsite", Target: "https://offsite.com", ExpiresAt: &expiresAt}
+
+	line := FormatEntry(entry)
+	if line != "offsite https://offsite.com expiresAt=2025-06-04T12:30:00Z" {
+		t.Errorf("FormatEntry() = %q", line)
+	}
+
+	parsed, err := parseLine(line, 1)
+	if err != nil {
+		t.Fatalf("parseLine() error = %v", err)
+	}
+	if !reflect.DeepEqual(parsed, entry) {
+		t.Errorf("round trip got %v, want %v", parsed, entry)
+	}
+}
+
+func TestFileStorage_Clicks(t *testing.T) {
+	createTestFile()
+	f := NewFileStorage(TEST_DIR + "/" + TEST_FILE)
+	defer os.Remove(f.getClicksConfigFilepath())
+	lastClicked := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	want := map[string]models.ClickStats{
+		"foo": {Count: 3, LastClickedAt: lastClicked, Popularity: 2.5},
+		"bar": {Count: 1, LastClickedAt: lastClicked, Popularity: 1},
+	}
+
+	f.WriteClicks(want)
+	got, err := f.ReadClicks()
+	if err != nil {
+		t.Fatalf("ReadClicks() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadClicks() = %v, want %v", got, want)
+	}
+	cleanup()
+}
+
+func TestParseClicksLine(t *testing.T) {
+	lastClicked := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		line    string
+		path    string
+		stats   models.ClickStats
+		wantErr bool
+	}{
+		{
+			name:  "Popularity",
+			line:  "foo 3 2025-06-01T12:00:00Z 2.5",
+			path:  "foo",
+			stats: models.ClickStats{Count: 3, LastClickedAt: lastClicked, Popularity: 2.5},
+		},
+		{name: "Without popularity", line: "foo 3 2025-06-01T12:00:00Z", wantErr: true},
+		{name: "Blank line", line: "  "},
+		{name: "Invalid popularity", line: "foo 3 2025-06-01T12:00:00Z lots", wantErr: true},
+		{name: "Missing fields", line: "foo 3", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, stats, err := parseClicksLine(tt.line)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseClicksLine() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if path != tt.path || stats != tt.stats {
+				t.Errorf("parseClicksLine() = %q, %v, want %q, %v", path, stats, tt.path, tt.stats)
 			}
 		})
 	}
 }
+
+func TestFileStorage_Check(t *testing.T) {
+	createTestFile()
+	f := NewFileStorage(TEST_DIR + "/" + TEST_FILE)
+	if err := f.Check(); err != nil {
+		t.Fatalf("Check() error = %v, want nil", err)
+	}
+
+	cleanup()
+	if err := f.Check(); err == nil {
+		t.Error("Check() error = nil after the config was removed")
+	}
+
+	createTestFile()
+	if err := f.Check(); err != nil {
+		t.Errorf("Check() error = %v after the config was restored", err)
+	}
+
+	f.watcher.Close()
+	deadline := time.Now().Add(time.Second)
+	for f.Check() == nil && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := f.Check(); err == nil {
+		t.Error("Check() error = nil after the watcher stopped")
+	}
+	cleanup()
+}
+
+func TestFileStorage_Close(t *testing.T) {
+	createTestFile()
+	f := NewFileStorage(TEST_DIR + "/" + TEST_FILE)
+	// Nobody reads the reload signal for this write, which must not keep the
+	// watcher from stopping.
+	f.Put(&models.Entry{Path: "baz", Target: "https://baz.com"})
+	time.Sleep(100 * time.Millisecond)
+
+	if err := f.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	select {
+	case _, ok := <-f.GetReloadChannel():
+		if ok {
+			t.Error("received a reload signal after Close")
+		}
+	case <-time.After(time.Second):
+		t.Error("reload channel was not closed")
+	}
+	cleanup()
+}
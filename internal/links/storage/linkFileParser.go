@@ -2,51 +2,229 @@ package storage
 
 import (
 	"bufio"
+	"errors"
+	"fmt"
 	"io"
 	"net/url"
 	"strings"
+	"time"
 	"unicode"
 
+	"github.com/dfryer1193/golinks/models"
 	"github.com/rs/zerolog/log"
 )
 
-func parseLinksFile(reader io.Reader) (map[string]string, error) {
-	newLinks := make(map[string]string)
+const (
+	attrActiveFrom  = "activeFrom"
+	attrExpiresAt   = "expiresAt"
+	attrDeletedAt   = "deletedAt"
+	attrAliasOf     = "aliasOf"
+	attrRedirect    = "redirect"
+	attrDescription = "description"
+	attrOwner       = "owner"
+	attrTags        = "tags"
+	attrCreatedAt   = "createdAt"
+	attrUpdatedAt   = "updatedAt"
+
+	// aliasTargetPlaceholder fills the target column for aliases, which take
+	// their target from the link they point at.
+	aliasTargetPlaceholder = "-"
+)
+
+// Line is an entry of a links file and the line it was read from.
+type Line struct {
+	Number int
+	Entry  *models.Entry
+}
+
+// ParseLines parses a links file into its entries, in the order they appear.
+func ParseLines(reader io.Reader) ([]Line, error) {
+	var lines []Line
 	sc := bufio.NewScanner(reader)
 	lineNum := 0
 
 	for sc.Scan() {
 		lineNum++
-		line := sc.Text()
-		key, target, err := parseLine(line, lineNum)
+		entry, err := parseLine(sc.Text(), lineNum)
 		if err != nil {
 			return nil, err
 		}
-		if key == "" && target == nil {
+		if entry == nil {
 			continue
 		}
-		newLinks[key] = target.String()
+		lines = append(lines, Line{Number: lineNum, Entry: entry})
+	}
+	// A line the scanner cannot read, such as one longer than its buffer, is
+	// reported against the line after the last one read.
+	if err := sc.Err(); err != nil {
+		return nil, &ParseError{Line: lineNum + 1, Err: err}
+	}
+
+	return lines, nil
+}
+
+func parseLinksFile(reader io.Reader) (map[string]*models.Entry, error) {
+	lines, err := ParseLines(reader)
+	if err != nil {
+		return nil, err
 	}
 
+	newLinks := make(map[string]*models.Entry, len(lines))
+	for _, line := range lines {
+		newLinks[line.Entry.Path] = line.Entry
+	}
 	return newLinks, nil
 }
 
-func parseLine(line string, lineNum int) (string, *url.URL, error) {
+// parseLine parses a single line of the links file. A line consists of a path
+// and a target, optionally followed by attributes in the form name=value.
+// Blank lines yield a nil entry.
+func parseLine(line string, lineNum int) (*models.Entry, error) {
 	parts := strings.FieldsFunc(strings.TrimSpace(line), func(c rune) bool { return unicode.IsSpace(c) })
-	if len(parts) != 2 {
+	if len(parts) < 2 {
 		if len(parts) == 0 {
-			return "", nil, nil
+			return nil, nil
 		}
-		err := &ParseError{}
-		log.Error().Err(err).Int("line", lineNum).Msg("Malformed config. Each non-empty line must have exactly two entries.")
-		return "", nil, err
+		err := &ParseError{Line: lineNum, Err: errors.New("each non-empty line must have a path and a target")}
+		log.Error().Err(err).Int("line", lineNum).Msg("Malformed config. Each non-empty line must have a path and a target.")
+		return nil, err
 	}
 
 	target, err := url.Parse(parts[1])
 	if err != nil {
 		log.Err(err).Int("line", lineNum).Str("url", parts[1]).Msg("Malformed config. Invalid url")
-		return "", nil, &ParseError{}
+		return nil, &ParseError{Line: lineNum, Err: err}
+	}
+
+	entry := &models.Entry{
+		Path:   parts[0],
+		Target: target.String(),
 	}
 
-	return parts[0], target, nil
+	for _, attr := range parts[2:] {
+		if err := parseAttribute(entry, attr); err != nil {
+			log.Err(err).Int("line", lineNum).Str("attribute", attr).Msg("Malformed config. Invalid attribute")
+			return nil, &ParseError{Line: lineNum, Err: fmt.Errorf("invalid attribute %q: %w", attr, err)}
+		}
+	}
+
+	if entry.AliasOf != "" {
+		entry.Target = ""
+	}
+
+	return entry, nil
+}
+
+func parseAttribute(entry *models.Entry, attr string) error {
+	name, rawValue, found := strings.Cut(attr, "=")
+	if !found {
+		return errors.New("attributes must be written as name=value")
+	}
+
+	value, err := url.PathUnescape(rawValue)
+	if err != nil {
+		return err
+	}
+
+	switch name {
+	case attrActiveFrom:
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return err
+		}
+		entry.ActiveFrom = &t
+	case attrExpiresAt:
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return err
+		}
+		entry.ExpiresAt = &t
+	case attrDeletedAt:
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return err
+		}
+		entry.DeletedAt = &t
+	case attrCreatedAt:
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return err
+		}
+		entry.CreatedAt = &t
+	case attrUpdatedAt:
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return err
+		}
+		entry.UpdatedAt = &t
+	case attrAliasOf:
+		entry.AliasOf = value
+	case attrDescription:
+		entry.Description = value
+	case attrOwner:
+		entry.Owner = value
+	case attrTags:
+		entry.Tags = strings.Split(value, ",")
+	case attrRedirect:
+		mode := models.RedirectMode(value)
+		if !mode.IsValid() {
+			return fmt.Errorf("unknown redirect mode %q", value)
+		}
+		entry.Redirect = mode
+	default:
+		log.Warn().Str("attribute", name).Str("path", entry.Path).Msg("Ignoring unknown link attribute")
+	}
+
+	return nil
+}
+
+// FormatEntry renders an entry as a single line of the links file, without the
+// trailing newline.
+func FormatEntry(entry *models.Entry) string {
+	var sb strings.Builder
+	sb.WriteString(entry.Path)
+	sb.WriteString(" ")
+	if entry.AliasOf != "" {
+		sb.WriteString(aliasTargetPlaceholder)
+		writeAttribute(&sb, attrAliasOf, entry.AliasOf)
+	} else {
+		sb.WriteString(entry.Target)
+	}
+
+	if entry.Description != "" {
+		writeAttribute(&sb, attrDescription, entry.Description)
+	}
+	if entry.Owner != "" {
+		writeAttribute(&sb, attrOwner, entry.Owner)
+	}
+	if len(entry.Tags) > 0 {
+		writeAttribute(&sb, attrTags, strings.Join(entry.Tags, ","))
+	}
+	if entry.Redirect != models.RedirectDefault {
+		writeAttribute(&sb, attrRedirect, string(entry.Redirect))
+	}
+	if entry.ActiveFrom != nil {
+		writeAttribute(&sb, attrActiveFrom, entry.ActiveFrom.UTC().Format(time.RFC3339))
+	}
+	if entry.ExpiresAt != nil {
+		writeAttribute(&sb, attrExpiresAt, entry.ExpiresAt.UTC().Format(time.RFC3339))
+	}
+	if entry.DeletedAt != nil {
+		writeAttribute(&sb, attrDeletedAt, entry.DeletedAt.UTC().Format(time.RFC3339))
+	}
+	if entry.CreatedAt != nil {
+		writeAttribute(&sb, attrCreatedAt, entry.CreatedAt.UTC().Format(time.RFC3339))
+	}
+	if entry.UpdatedAt != nil {
+		writeAttribute(&sb, attrUpdatedAt, entry.UpdatedAt.UTC().Format(time.RFC3339))
+	}
+
+	return sb.String()
+}
+
+func writeAttribute(sb *strings.Builder, name string, value string) {
+	sb.WriteString(" ")
+	sb.WriteString(name)
+	sb.WriteString("=")
+	sb.WriteString(url.PathEscape(value))
 }
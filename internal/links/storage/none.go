@@ -1,6 +1,9 @@
 package storage
 
-import "io"
+import (
+	"github.com/dfryer1193/golinks/models"
+	"io"
+)
 
 type NoneStorage struct{}
 
@@ -8,23 +11,54 @@ func NewNoneStorage() *NoneStorage {
 	return &NoneStorage{}
 }
 
-func (s *NoneStorage) Read() (map[string]string, error) {
-	return nil, nil
+func (s *NoneStorage) Read() (map[string]*models.Entry, error) {
+	return make(map[string]*models.Entry), nil
 }
 
-func (s *NoneStorage) Put(key string, target string) {
+func (s *NoneStorage) Put(entry *models.Entry) {
 }
 
 func (s *NoneStorage) Delete(key string) {
 }
 
-func (s *NoneStorage) Update(key string, value string) {
+func (s *NoneStorage) Update(entry *models.Entry) {
 }
 
-func (s *NoneStorage) ReplaceConfig(reader io.Reader) (map[string]string, error) {
+func (s *NoneStorage) Archive(entry *models.Entry) {
+}
+
+func (s *NoneStorage) ReadTrash() (map[string]*models.Entry, error) {
+	return make(map[string]*models.Entry), nil
+}
+
+func (s *NoneStorage) Trash(entry *models.Entry) {
+}
+
+func (s *NoneStorage) Restore(entry *models.Entry) {
+}
+
+func (s *NoneStorage) Purge(key string) {
+}
+
+func (s *NoneStorage) ReadClicks() (map[string]models.ClickStats, error) {
+	return make(map[string]models.ClickStats), nil
+}
+
+func (s *NoneStorage) WriteClicks(clicks map[string]models.ClickStats) {
+}
+
+func (s *NoneStorage) ReplaceConfig(reader io.Reader) (map[string]*models.Entry, error) {
 	return parseLinksFile(reader)
 }
 
 func (s *NoneStorage) GetReloadChannel() <-chan bool {
 	return nil
 }
+
+func (s *NoneStorage) Check() error {
+	return nil
+}
+
+func (s *NoneStorage) Close() error {
+	return nil
+}
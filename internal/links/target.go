@@ -0,0 +1,173 @@
+package links
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/dfryer1193/golinks/models"
+)
+
+// Reasons reported by a ValidationError for the target field.
+const (
+	ReasonInvalidURL       = "invalid_url"
+	ReasonSchemeNotAllowed = "scheme_not_allowed"
+	ReasonNotAbsolute      = "not_absolute"
+	ReasonDomainNotAllowed = "domain_not_allowed"
+	ReasonDomainDenied     = "domain_denied"
+	ReasonPrivateAddress   = "private_address"
+)
+
+// privateLookupTimeout bounds the DNS lookup used to block private targets.
+const privateLookupTimeout = 2 * time.Second
+
+// TargetPolicy describes which targets links may redirect to.
+type TargetPolicy struct {
+	// AllowedSchemes lists the URL schemes a target may use. An empty list
+	// allows any scheme.
+	AllowedSchemes []string
+	// RequireAbsolute rejects targets without a scheme and host.
+	RequireAbsolute bool
+	// AllowedDomains, if not empty, restricts targets to these domains and
+	// their subdomains.
+	AllowedDomains []string
+	// DeniedDomains rejects targets on these domains and their subdomains.
+	DeniedDomains []string
+	// BlockPrivateAddresses rejects targets that are, or resolve to, loopback,
+	// private or link-local addresses.
+	BlockPrivateAddresses bool
+}
+
+// DefaultTargetPolicy returns a policy that only allows absolute http and https
+// targets.
+func DefaultTargetPolicy() TargetPolicy {
+	return TargetPolicy{
+		AllowedSchemes:  []string{"http", "https"},
+		RequireAbsolute: true,
+	}
+}
+
+// WithTargetPolicy sets the policy new and updated targets are validated
+// against.
+func WithTargetPolicy(policy TargetPolicy) Option {
+	return func(l *LinkMap) {
+		l.targetPolicy = policy
+	}
+}
+
+// validateTarget checks an entry's target against the target policy. Aliases
+// have no target of their own and are not checked.
+func (l *LinkMap) validateTarget(ctx context.Context, entry *models.Entry) error {
+	if entry.AliasOf != "" {
+		return nil
+	}
+	if entry.Target == "" {
+		return &ValidationError{Field: "target", Reason: ReasonEmpty, Message: "target cannot be empty"}
+	}
+
+	target, err := url.Parse(entry.Target)
+	if err != nil {
+		return &ValidationError{Field: "target", Reason: ReasonInvalidURL, Message: fmt.Sprintf("%s is not a valid url", entry.Target)}
+	}
+	// The links file separates fields with whitespace, so a target may only
+	// keep what its canonical form does not escape.
+	for _, r := range target.String() {
+		switch {
+		case unicode.IsSpace(r):
+			return &ValidationError{Field: "target", Reason: ReasonWhitespace, Message: "target cannot contain whitespace"}
+		case unicode.IsControl(r):
+			return &ValidationError{Field: "target", Reason: ReasonControlCharacter, Message: "target cannot contain control characters"}
+		}
+	}
+
+	policy := l.targetPolicy
+	scheme := strings.ToLower(target.Scheme)
+	if policy.RequireAbsolute && scheme == "" {
+		return &ValidationError{Field: "target", Reason: ReasonNotAbsolute, Message: "target must be an absolute url"}
+	}
+	if len(policy.AllowedSchemes) > 0 && !slices.Contains(policy.AllowedSchemes, scheme) {
+		return &ValidationError{
+			Field:   "target",
+			Reason:  ReasonSchemeNotAllowed,
+			Message: fmt.Sprintf("scheme %q is not allowed; use one of %s", target.Scheme, strings.Join(policy.AllowedSchemes, ", ")),
+		}
+	}
+
+	host := strings.ToLower(target.Hostname())
+	if policy.RequireAbsolute && (!target.IsAbs() || host == "") {
+		return &ValidationError{Field: "target", Reason: ReasonNotAbsolute, Message: "target must be an absolute url"}
+	}
+	if host == "" {
+		return nil
+	}
+
+	if len(policy.AllowedDomains) > 0 && !MatchesDomain(host, policy.AllowedDomains) {
+		return &ValidationError{Field: "target", Reason: ReasonDomainNotAllowed, Message: fmt.Sprintf("%s is not an allowed domain", host)}
+	}
+	if MatchesDomain(host, policy.DeniedDomains) {
+		return &ValidationError{Field: "target", Reason: ReasonDomainDenied, Message: fmt.Sprintf("%s is a denied domain", host)}
+	}
+
+	if policy.BlockPrivateAddresses && l.isPrivateHost(ctx, host) {
+		return &ValidationError{Field: "target", Reason: ReasonPrivateAddress, Message: fmt.Sprintf("%s is a private address", host)}
+	}
+
+	return nil
+}
+
+// cleanTarget replaces an entry's target with its canonical form, which escapes
+// the spaces a pasted url may contain. Targets that do not parse are left for
+// validateTarget to reject.
+func cleanTarget(entry *models.Entry) {
+	if entry.AliasOf != "" {
+		return
+	}
+	if target, err := url.Parse(entry.Target); err == nil {
+		entry.Target = target.String()
+	}
+}
+
+// MatchesDomain reports whether host is one of the domains or a subdomain of
+// one of them.
+func MatchesDomain(host string, domains []string) bool {
+	for _, domain := range domains {
+		domain = strings.ToLower(strings.TrimPrefix(domain, "."))
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// isPrivateHost reports whether host is a private address, or a name that
+// resolves to one. Names that cannot be resolved are not considered private.
+func (l *LinkMap) isPrivateHost(ctx context.Context, host string) bool {
+	if ip := net.ParseIP(host); ip != nil {
+		return isPrivateIP(ip)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, privateLookupTimeout)
+	defer cancel()
+	ips, err := l.lookupIP(ctx, host)
+	if err != nil {
+		return false
+	}
+	return slices.ContainsFunc(ips, isPrivateIP)
+}
+
+func isPrivateIP(ip net.IP) bool {
+	return ip.IsPrivate() ||
+		ip.IsLoopback() ||
+		ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() ||
+		ip.IsUnspecified()
+}
+
+func lookupIP(ctx context.Context, host string) ([]net.IP, error) {
+	return net.DefaultResolver.LookupIP(ctx, "ip", host)
+}
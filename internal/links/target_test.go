@@ -0,0 +1,117 @@
+package links
+
+import (
+	"context"
+	"errors"
+	"github.com/dfryer1193/golinks/internal/links/storage"
+	"github.com/dfryer1193/golinks/models"
+	"net"
+	"os"
+	"testing"
+)
+
+func TestLinkMap_ValidateTarget(t *testing.T) {
+	links := NewLinkMap(storage.NONE, "", WithTargetPolicy(TargetPolicy{
+		AllowedSchemes:        []string{"http", "https"},
+		RequireAbsolute:       true,
+		DeniedDomains:         []string{"evil.com"},
+		BlockPrivateAddresses: true,
+	}))
+	links.lookupIP = func(ctx context.Context, host string) ([]net.IP, error) {
+		switch host {
+		case "intranet.corp":
+			return []net.IP{net.ParseIP("10.0.0.5")}, nil
+		case "example.com":
+			return []net.IP{net.ParseIP("93.184.216.34")}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+	tests := []struct {
+		name   string
+		target string
+		reason string
+	}{
+		{name: "Accepts an https url", target: "https://example.com/docs", reason: ""},
+		{name: "Accepts a host that cannot be resolved", target: "https://unknown.example", reason: ""},
+		{name: "Rejects an empty target", target: "", reason: ReasonEmpty},
+		{name: "Accepts a path with a space", target: "https://example.com/a b", reason: ""},
+		{name: "Rejects a query with a space", target: "https://example.com/?q=a b", reason: ReasonWhitespace},
+		{name: "Rejects a non-breaking space", target: "https://example.com/?q=a\u00a0b", reason: ReasonWhitespace},
+		{name: "Rejects an unparseable url", target: "https://exa mple.com/%zz", reason: ReasonInvalidURL},
+		{name: "Rejects javascript urls", target: "javascript:alert(1)", reason: ReasonSchemeNotAllowed},
+		{name: "Rejects relative paths", target: "/docs", reason: ReasonNotAbsolute},
+		{name: "Rejects urls without a scheme", target: "example.com/docs", reason: ReasonNotAbsolute},
+		{name: "Rejects denied domains", target: "https://evil.com", reason: ReasonDomainDenied},
+		{name: "Rejects subdomains of denied domains", target: "https://www.evil.com", reason: ReasonDomainDenied},
+		{name: "Rejects private ip literals", target: "http://192.168.1.1/admin", reason: ReasonPrivateAddress},
+		{name: "Rejects loopback addresses", target: "http://[::1]:8080", reason: ReasonPrivateAddress},
+		{name: "Rejects names resolving to private addresses", target: "https://intranet.corp", reason: ReasonPrivateAddress},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := links.Put(context.Background(), &models.Entry{Path: "target", Target: tt.target})
+			if tt.reason == "" {
+				if err != nil {
+					t.Fatalf("Put(%q) error = %v", tt.target, err)
+				}
+				return
+			}
+
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("Put(%q) error = %v, want a ValidationError", tt.target, err)
+			}
+			if validationErr.Field != "target" || validationErr.Reason != tt.reason {
+				t.Errorf("Put(%q) got %s/%s, want target/%s", tt.target, validationErr.Field, validationErr.Reason, tt.reason)
+			}
+		})
+	}
+}
+
+func TestLinkMap_ValidateTargetAllowedDomains(t *testing.T) {
+	links := NewLinkMap(storage.NONE, "", WithTargetPolicy(TargetPolicy{
+		AllowedDomains: []string{"corp.example"},
+	}))
+
+	if err := links.Put(context.Background(), &models.Entry{Path: "wiki", Target: "https://wiki.corp.example"}); err != nil {
+		t.Errorf("subdomain of an allowed domain should be accepted, got %v", err)
+	}
+
+	err := links.Put(context.Background(), &models.Entry{Path: "other", Target: "https://other.example"})
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Reason != ReasonDomainNotAllowed {
+		t.Errorf("domain outside the allow list should be rejected, got %v", err)
+	}
+}
+
+func TestLinkMap_TargetWithSpaceSurvivesReload(t *testing.T) {
+	path := t.TempDir() + "/links"
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	links := NewLinkMap(storage.FILE, path)
+	ctx := context.Background()
+	if err := links.Put(ctx, &models.Entry{Path: "spaced", Target: "https://example.com/a b"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := links.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	lines, err := storage.ParseLines(file)
+	if err != nil {
+		t.Fatalf("ParseLines() error = %v", err)
+	}
+	if len(lines) != 1 {
+		t.Fatalf("saved %d lines, want 1", len(lines))
+	}
+	if got, want := lines[0].Entry.Target, "https://example.com/a%20b"; got != want {
+		t.Errorf("saved target = %q, want %q", got, want)
+	}
+}
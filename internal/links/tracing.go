@@ -0,0 +1,34 @@
+package links
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/dfryer1193/golinks/internal/links")
+
+// startSpan starts a span for a link map operation on path.
+func startSpan(ctx context.Context, name string, path string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "LinkMap."+name, trace.WithAttributes(attribute.String("golinks.path", path)))
+}
+
+// endSpan records err, if any, on span and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// traceStorage runs a synchronous storage call in a span.
+func traceStorage[T any](ctx context.Context, name string, call func() (T, error)) (T, error) {
+	_, span := tracer.Start(ctx, "storage."+name)
+	result, err := call()
+	endSpan(span, err)
+	return result, err
+}
@@ -0,0 +1,70 @@
+package links
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dfryer1193/golinks/internal/links/storage"
+	"github.com/dfryer1193/golinks/models"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestLinkMap_Tracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	otel.SetTracerProvider(provider)
+
+	links := NewLinkMap(storage.NONE, "")
+	ctx, request := provider.Tracer("test").Start(context.Background(), "request")
+	if err := links.Put(ctx, &models.Entry{Path: "docs", Target: "https://docs.example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	request.End()
+
+	spans := func() map[string]tracetest.SpanStub {
+		byName := map[string]tracetest.SpanStub{}
+		for _, span := range exporter.GetSpans() {
+			byName[span.Name] = span
+		}
+		return byName
+	}
+	// The storage write happens in the background.
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if _, exists := spans()["storage.Put"]; exists {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	byName := spans()
+	put, exists := byName["LinkMap.Put"]
+	if !exists {
+		t.Fatal("no LinkMap.Put span")
+	}
+	if put.Parent.SpanID() != request.SpanContext().SpanID() {
+		t.Error("LinkMap.Put is not a child of the request span")
+	}
+	write, exists := byName["storage.Put"]
+	if !exists {
+		t.Fatal("no storage.Put span")
+	}
+	if write.Parent.SpanID() != put.SpanContext.SpanID() {
+		t.Error("storage.Put is not a child of LinkMap.Put")
+	}
+
+	exporter.Reset()
+	if _, err := links.Resolve(ctx, "missing"); err == nil {
+		t.Fatal("expected missing link to fail to resolve")
+	}
+	resolve := exporter.GetSpans()
+	if len(resolve) != 1 || resolve[0].Name != "LinkMap.Resolve" {
+		t.Fatalf("got spans %v, want LinkMap.Resolve", resolve)
+	}
+	if len(resolve[0].Events) != 0 {
+		t.Error("a missing link should not be recorded as an error")
+	}
+}
@@ -0,0 +1,243 @@
+package links
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/dfryer1193/golinks/models"
+)
+
+const (
+	// DefaultPathChars is the default set of characters allowed in a path,
+	// written as the body of a regular expression character class.
+	DefaultPathChars = `\p{L}\p{N}_.~-`
+	// DefaultMaxPathLength is the default maximum length of a path in runes.
+	DefaultMaxPathLength = 64
+)
+
+// Reasons reported by a ValidationError.
+const (
+	ReasonEmpty            = "empty"
+	ReasonTooLong          = "too_long"
+	ReasonWhitespace       = "whitespace"
+	ReasonControlCharacter = "control_character"
+	ReasonInvalidCharacter = "invalid_character"
+	ReasonReserved         = "reserved"
+	ReasonInvalidRedirect  = "invalid_redirect"
+	ReasonInvalidTag       = "invalid_tag"
+	ReasonConflict         = "conflict"
+	ReasonInvalidSchedule  = "invalid_schedule"
+	ReasonInvalidAlias     = "invalid_alias"
+	ReasonMalformed        = "malformed"
+)
+
+// ErrValidation is wrapped by every ValidationError.
+var ErrValidation = errors.New("validation failed")
+
+// ValidationError describes why a field of a link was rejected. Reason is a
+// stable, machine-readable code; Message is meant for people. Err is the
+// error behind the reason, if there is one. Path and Line are only set for
+// links read from an imported file.
+type ValidationError struct {
+	Field   string
+	Reason  string
+	Message string
+	Err     error
+	Path    string
+	Line    int
+}
+
+func (e *ValidationError) Error() string {
+	if e.Line > 0 && e.Path == "" {
+		return fmt.Sprintf("line %d: invalid %s: %s", e.Line, e.Field, e.Message)
+	}
+	if e.Line > 0 {
+		return fmt.Sprintf("line %d (%s): invalid %s: %s", e.Line, e.Path, e.Field, e.Message)
+	}
+	return fmt.Sprintf("invalid %s: %s", e.Field, e.Message)
+}
+
+func (e *ValidationError) Unwrap() []error {
+	if e.Err != nil {
+		return []error{ErrValidation, e.Err}
+	}
+	return []error{ErrValidation}
+}
+
+// PathPolicy describes which paths may be used for links.
+type PathPolicy struct {
+	// Reserved paths are matched after normalization, so reserving "api" also
+	// reserves "API".
+	Reserved []string
+	// AllowedChars is the body of a regular expression character class that
+	// every character of a path must belong to.
+	AllowedChars string
+	// MaxLength is the maximum length of a path in runes.
+	MaxLength int
+}
+
+// DefaultPathPolicy returns a policy with the default character set and length
+// and no reserved paths.
+func DefaultPathPolicy() PathPolicy {
+	return PathPolicy{
+		AllowedChars: DefaultPathChars,
+		MaxLength:    DefaultMaxPathLength,
+	}
+}
+
+// pathValidator is a compiled PathPolicy.
+type pathValidator struct {
+	reserved  map[string]bool
+	allowed   *regexp.Regexp
+	maxLength int
+}
+
+// WithPathPolicy sets the policy new and updated paths are validated against.
+// NewLinkMap panics if the policy's character set is not a valid character
+// class; use CompilePathChars to check it beforehand.
+func WithPathPolicy(policy PathPolicy) Option {
+	return func(l *LinkMap) {
+		l.pathPolicy = policy
+	}
+}
+
+// CompilePathChars checks that chars is a valid character class body.
+func CompilePathChars(chars string) (*regexp.Regexp, error) {
+	return regexp.Compile("^[" + chars + "]$")
+}
+
+func (l *LinkMap) buildPathValidator() *pathValidator {
+	allowed, err := CompilePathChars(l.pathPolicy.AllowedChars)
+	if err != nil {
+		panic(fmt.Sprintf("invalid path character set %q: %v", l.pathPolicy.AllowedChars, err))
+	}
+
+	reserved := make(map[string]bool, len(l.pathPolicy.Reserved))
+	for _, path := range l.pathPolicy.Reserved {
+		reserved[l.key(path)] = true
+	}
+
+	return &pathValidator{
+		reserved:  reserved,
+		allowed:   allowed,
+		maxLength: l.pathPolicy.MaxLength,
+	}
+}
+
+// validateEntry checks everything about an entry that does not depend on the
+// other links: its path, its target, its redirect mode, its schedule, its tags
+// and that an alias does not also have a target. It returns the cleaned copy of
+// entry that should be stored.
+func (l *LinkMap) validateEntry(ctx context.Context, entry *models.Entry) (*models.Entry, error) {
+	if err := l.validatePath(entry); err != nil {
+		return nil, err
+	}
+	if err := l.validateTarget(ctx, entry); err != nil {
+		return nil, err
+	}
+	if err := validateRedirect(entry); err != nil {
+		return nil, err
+	}
+	if err := validateSchedule(entry); err != nil {
+		return nil, err
+	}
+	cleaned := stored(entry)
+	cleanTarget(cleaned)
+	cleanTags(cleaned)
+	if err := validateTags(cleaned); err != nil {
+		return nil, err
+	}
+	if cleaned.AliasOf != "" && cleaned.Target != "" {
+		return nil, aliasError(ErrAliasHasTarget)
+	}
+	return cleaned, nil
+}
+
+// validatePath checks an entry's path against the path policy.
+func (l *LinkMap) validatePath(entry *models.Entry) error {
+	path := entry.Path
+	if path == "" || l.key(path) == "" {
+		return &ValidationError{Field: "path", Reason: ReasonEmpty, Message: "path cannot be empty"}
+	}
+
+	if l.pathValidator.maxLength > 0 && utf8.RuneCountInString(path) > l.pathValidator.maxLength {
+		return &ValidationError{
+			Field:   "path",
+			Reason:  ReasonTooLong,
+			Message: fmt.Sprintf("path cannot be longer than %d characters", l.pathValidator.maxLength),
+		}
+	}
+
+	for _, r := range path {
+		switch {
+		case unicode.IsSpace(r):
+			return &ValidationError{Field: "path", Reason: ReasonWhitespace, Message: "path cannot contain whitespace"}
+		case unicode.IsControl(r):
+			return &ValidationError{Field: "path", Reason: ReasonControlCharacter, Message: "path cannot contain control characters"}
+		case !l.pathValidator.allowed.MatchString(string(r)):
+			return &ValidationError{
+				Field:   "path",
+				Reason:  ReasonInvalidCharacter,
+				Message: fmt.Sprintf("path cannot contain %q", r),
+			}
+		}
+	}
+
+	if l.pathValidator.reserved[l.key(path)] {
+		return &ValidationError{
+			Field:   "path",
+			Reason:  ReasonReserved,
+			Message: fmt.Sprintf("%s is reserved", path),
+		}
+	}
+
+	return nil
+}
+
+// cleanTags lowercases an entry's tags and drops empty and duplicate ones.
+func cleanTags(entry *models.Entry) {
+	tags := make([]string, 0, len(entry.Tags))
+	for _, tag := range entry.Tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" && !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	entry.Tags = tags
+	if len(tags) == 0 {
+		entry.Tags = nil
+	}
+}
+
+// validateTags checks that no tag contains a comma or whitespace, which would
+// make it ambiguous in the links file and in search.
+func validateTags(entry *models.Entry) error {
+	for _, tag := range entry.Tags {
+		if strings.ContainsFunc(tag, func(r rune) bool { return r == ',' || unicode.IsSpace(r) }) {
+			return &ValidationError{
+				Field:   "tags",
+				Reason:  ReasonInvalidTag,
+				Message: fmt.Sprintf("tag %q cannot contain commas or whitespace", tag),
+			}
+		}
+	}
+	return nil
+}
+
+// validateRedirect checks that an entry uses a known redirect mode.
+func validateRedirect(entry *models.Entry) error {
+	if !entry.Redirect.IsValid() {
+		return &ValidationError{
+			Field:   "redirect",
+			Reason:  ReasonInvalidRedirect,
+			Message: fmt.Sprintf("%q is not one of 301, 302, 307, 308 or interstitial", entry.Redirect),
+		}
+	}
+	return nil
+}
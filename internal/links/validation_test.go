@@ -0,0 +1,106 @@
+package links
+
+import (
+	"context"
+	"errors"
+	"github.com/dfryer1193/golinks/internal/links/storage"
+	"github.com/dfryer1193/golinks/models"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLinkMap_ValidatePath(t *testing.T) {
+	links := NewLinkMap(storage.NONE, "", WithPathPolicy(PathPolicy{
+		Reserved:     []string{"api", "styles.css"},
+		AllowedChars: DefaultPathChars,
+		MaxLength:    16,
+	}))
+	tests := []struct {
+		name   string
+		path   string
+		reason string
+	}{
+		{name: "Accepts a plain path", path: "docs", reason: ""},
+		{name: "Accepts unicode letters", path: "café", reason: ""},
+		{name: "Rejects an empty path", path: "", reason: ReasonEmpty},
+		{name: "Rejects a path of only separators", path: "--", reason: ReasonEmpty},
+		{name: "Rejects a reserved path", path: "api", reason: ReasonReserved},
+		{name: "Rejects a reserved path after normalization", path: "Styles_CSS", reason: ReasonReserved},
+		{name: "Rejects whitespace", path: "on call", reason: ReasonWhitespace},
+		{name: "Rejects control characters", path: "on\x00call", reason: ReasonControlCharacter},
+		{name: "Rejects characters outside the character set", path: "foo+", reason: ReasonInvalidCharacter},
+		{name: "Rejects long paths", path: strings.Repeat("a", 17), reason: ReasonTooLong},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := links.Put(context.Background(), &models.Entry{Path: tt.path, Target: "https://example.com"})
+			if tt.reason == "" {
+				if err != nil {
+					t.Fatalf("Put(%q) error = %v", tt.path, err)
+				}
+				return
+			}
+
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("Put(%q) error = %v, want a ValidationError", tt.path, err)
+			}
+			if validationErr.Field != "path" || validationErr.Reason != tt.reason {
+				t.Errorf("Put(%q) got %s/%s, want path/%s", tt.path, validationErr.Field, validationErr.Reason, tt.reason)
+			}
+			if !errors.Is(err, ErrValidation) {
+				t.Error("ValidationError should wrap ErrValidation")
+			}
+		})
+	}
+}
+
+func TestLinkMap_ValidateRedirect(t *testing.T) {
+	links := NewLinkMap(storage.NONE, "")
+	tests := []struct {
+		name     string
+		redirect models.RedirectMode
+		wantErr  bool
+	}{
+		{name: "Accepts the default mode", redirect: models.RedirectDefault},
+		{name: "Accepts a permanent redirect", redirect: models.RedirectPermanent},
+		{name: "Accepts an interstitial", redirect: models.RedirectInterstitial},
+		{name: "Rejects an unknown status", redirect: "200", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := links.Put(context.Background(), &models.Entry{Path: "docs", Target: "https://example.com", Redirect: tt.redirect})
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("Put() error = %v", err)
+				}
+				return
+			}
+
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) || validationErr.Reason != ReasonInvalidRedirect {
+				t.Errorf("Put() error = %v, want reason %s", err, ReasonInvalidRedirect)
+			}
+		})
+	}
+}
+
+func TestLinkMap_PutCleansTags(t *testing.T) {
+	links := NewLinkMap(storage.NONE, "")
+
+	err := links.Put(context.Background(), &models.Entry{Path: "docs", Target: "https://example.com", Tags: []string{" Eng", "docs", "", "eng"}})
+	if err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	entry, _ := links.GetEntry("docs")
+	if !reflect.DeepEqual(entry.Tags, []string{"eng", "docs"}) {
+		t.Errorf("Tags = %v, want [eng docs]", entry.Tags)
+	}
+
+	err = links.Put(context.Background(), &models.Entry{Path: "wiki", Target: "https://example.com", Tags: []string{"on call"}})
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Reason != ReasonInvalidTag {
+		t.Errorf("Put() error = %v, want reason %s", err, ReasonInvalidTag)
+	}
+}
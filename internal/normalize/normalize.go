@@ -0,0 +1,108 @@
+package normalize
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// separators are the characters removed by the StripSeparators rule.
+const separators = "-_."
+
+// Rule is a single transformation applied when normalizing a path.
+type Rule int
+
+const (
+	// FoldCase makes matching case-insensitive, e.g. "OnCall" == "oncall".
+	FoldCase Rule = iota
+	// StripSeparators removes '-', '_' and '.', e.g. "on-call" == "on_call".
+	StripSeparators
+	// NFC composes Unicode characters so that visually identical paths match.
+	NFC
+)
+
+func (r Rule) String() string {
+	return [...]string{"case", "separators", "nfc"}[r]
+}
+
+// RuleFromString parses the name of a single rule.
+func RuleFromString(s string) (Rule, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "case":
+		return FoldCase, nil
+	case "separators":
+		return StripSeparators, nil
+	case "nfc":
+		return NFC, nil
+	default:
+		return 0, fmt.Errorf("unknown normalization rule %q", s)
+	}
+}
+
+// ParseRules parses a comma separated list of rule names. "none" or an empty
+// string disables normalization entirely.
+func ParseRules(s string) ([]Rule, error) {
+	if s == "" || strings.EqualFold(s, "none") {
+		return nil, nil
+	}
+
+	var rules []Rule
+	for _, name := range strings.Split(s, ",") {
+		rule, err := RuleFromString(name)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// Normalizer maps link paths onto the key they are stored and matched under.
+type Normalizer struct {
+	foldCase        bool
+	stripSeparators bool
+	nfc             bool
+}
+
+// New returns a Normalizer applying the given rules. With no rules, paths are
+// used as-is.
+func New(rules ...Rule) *Normalizer {
+	n := &Normalizer{}
+	for _, rule := range rules {
+		switch rule {
+		case FoldCase:
+			n.foldCase = true
+		case StripSeparators:
+			n.stripSeparators = true
+		case NFC:
+			n.nfc = true
+		}
+	}
+	return n
+}
+
+// Default returns a Normalizer applying every rule.
+func Default() *Normalizer {
+	return New(FoldCase, StripSeparators, NFC)
+}
+
+// Normalize returns the key for path.
+func (n *Normalizer) Normalize(path string) string {
+	if n.foldCase {
+		path = cases.Fold().String(path)
+	}
+	if n.stripSeparators {
+		path = strings.Map(func(r rune) rune {
+			if strings.ContainsRune(separators, r) {
+				return -1
+			}
+			return r
+		}, path)
+	}
+	if n.nfc {
+		path = norm.NFC.String(path)
+	}
+	return path
+}
@@ -0,0 +1,53 @@
+package normalize
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizer_Normalize(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []Rule
+		path  string
+		want  string
+	}{
+		{name: "No rules leaves the path as-is", rules: nil, path: "On-Call", want: "On-Call"},
+		{name: "Folds case", rules: []Rule{FoldCase}, path: "OnCall", want: "oncall"},
+		{name: "Strips separators", rules: []Rule{StripSeparators}, path: "on-call_rota.v2", want: "oncallrotav2"},
+		{name: "Composes unicode", rules: []Rule{NFC}, path: "cafe\u0301", want: "caf\u00e9"},
+		{name: "Applies every rule", rules: []Rule{FoldCase, StripSeparators, NFC}, path: "On_Call-CAFE\u0301", want: "oncallcaf\u00e9"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := New(tt.rules...).Normalize(tt.path); got != tt.want {
+				t.Errorf("Normalize(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []Rule
+		wantErr bool
+	}{
+		{name: "Parses a list of rules", input: "case,separators,nfc", want: []Rule{FoldCase, StripSeparators, NFC}},
+		{name: "Ignores case and whitespace", input: "Case, NFC", want: []Rule{FoldCase, NFC}},
+		{name: "None disables normalization", input: "none", want: nil},
+		{name: "Rejects unknown rules", input: "case,soundex", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRules(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRules(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseRules(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
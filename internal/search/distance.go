@@ -0,0 +1,154 @@
+package search
+
+import "unicode/utf8"
+
+// editDistance returns the optimal string alignment distance between a and b:
+// the number of rune insertions, deletions, substitutions and transpositions
+// of adjacent runes needed to turn one into the other, editing no substring
+// more than once. It gives up as soon as the distance is known to be larger
+// than maxDistance, and returns maxDistance+1 in that case.
+func editDistance(a, b string, maxDistance int) int {
+	s, t := []rune(a), []rune(b)
+	if abs(len(s)-len(t)) > maxDistance {
+		return maxDistance + 1
+	}
+
+	// Only the last three rows of the distance matrix are needed: the current
+	// row, the previous one, and the one before that for transpositions.
+	prevPrev := make([]int, len(t)+1)
+	prev := make([]int, len(t)+1)
+	curr := make([]int, len(t)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(s); i++ {
+		curr[0] = i
+		rowMin := curr[0]
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+
+			d := min(
+				prev[j]+1,      // deletion
+				curr[j-1]+1,    // insertion
+				prev[j-1]+cost, // substitution
+			)
+			if i > 1 && j > 1 && s[i-1] == t[j-2] && s[i-2] == t[j-1] {
+				d = min(d, prevPrev[j-2]+1) // transposition
+			}
+
+			curr[j] = d
+			rowMin = min(rowMin, d)
+		}
+
+		// No cell in a later row, transpositions included, can be smaller than
+		// the smallest value of this row, so the limit is already exceeded.
+		if rowMin > maxDistance {
+			return maxDistance + 1
+		}
+		prevPrev, prev, curr = prev, curr, prevPrev
+	}
+
+	return min(prev[len(t)], maxDistance+1)
+}
+
+// damerauLevenshtein returns the unrestricted Damerau-Levenshtein distance
+// between s and t. Unlike the optimal string alignment distance it allows
+// editing a substring more than once, which makes it a metric as the BK-tree
+// requires, and never larger than editDistance.
+func damerauLevenshtein(s, t []rune) int {
+	return newDistanceScratch().damerauLevenshtein(s, t)
+}
+
+// distanceScratch holds the buffers of damerauLevenshtein, so that they can be
+// reused across the many comparisons of a BK-tree search.
+type distanceScratch struct {
+	matrix []int
+	// lastRow holds, for each rune, the last row of s it was seen in. ASCII,
+	// which most paths are made of, skips the map.
+	lastRow      map[rune]int
+	lastRowASCII [utf8.RuneSelf]int
+}
+
+func newDistanceScratch() *distanceScratch {
+	return &distanceScratch{lastRow: make(map[rune]int)}
+}
+
+func (scratch *distanceScratch) damerauLevenshtein(s, t []rune) int {
+	// The matrix is offset by one row and column, which hold a distance larger
+	// than any real one so that transpositions never reach past the start.
+	width := len(t) + 2
+	size := (len(s) + 2) * width
+	if cap(scratch.matrix) < size {
+		scratch.matrix = make([]int, size)
+	}
+	d := scratch.matrix[:size]
+	at := func(i, j int) int { return i*width + j }
+
+	maxDistance := len(s) + len(t)
+	for i := 0; i < len(s)+2; i++ {
+		d[at(i, 0)] = maxDistance
+		if i > 0 {
+			d[at(i, 1)] = i - 1
+		}
+	}
+	for j := 1; j < width; j++ {
+		d[at(0, j)] = maxDistance
+		d[at(1, j)] = j - 1
+	}
+
+	clear(scratch.lastRow)
+	for _, r := range s {
+		if r < utf8.RuneSelf {
+			scratch.lastRowASCII[r] = 0
+		}
+	}
+	for i := 1; i <= len(s); i++ {
+		// lastCol is the last column of this row where the runes matched.
+		lastCol := 0
+		for j := 1; j <= len(t); j++ {
+			k := scratch.lastRowOf(t[j-1])
+			l := lastCol
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+				lastCol = j
+			}
+
+			d[at(i+1, j+1)] = min(
+				d[at(i, j)]+cost,              // substitution
+				d[at(i+1, j)]+1,               // insertion
+				d[at(i, j+1)]+1,               // deletion
+				d[at(k, l)]+(i-k-1)+1+(j-l-1), // transposition
+			)
+		}
+		scratch.setLastRow(s[i-1], i)
+	}
+
+	return d[at(len(s)+1, len(t)+1)]
+}
+
+func (scratch *distanceScratch) lastRowOf(r rune) int {
+	if r < utf8.RuneSelf {
+		return scratch.lastRowASCII[r]
+	}
+	return scratch.lastRow[r]
+}
+
+func (scratch *distanceScratch) setLastRow(r rune, row int) {
+	if r < utf8.RuneSelf {
+		scratch.lastRowASCII[r] = row
+	} else {
+		scratch.lastRow[r] = row
+	}
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,132 @@
+package search
+
+import (
+	"math/rand/v2"
+	"testing"
+)
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{a: "", b: "", want: 0},
+		{a: "", b: "abc", want: 3},
+		{a: "abc", b: "", want: 3},
+		{a: "a", b: "b", want: 1},
+		{a: "kitten", b: "sitting", want: 3},
+		{a: "dashboard", b: "dahsboard", want: 1},
+		{a: "ca", b: "ac", want: 1},
+		{a: "abc", b: "ca", want: 3}, // no substring is edited twice
+		{a: "café", b: "cafe", want: 1},
+		{a: "日本語", b: "日語本", want: 1},
+		{a: "straße", b: "strasse", want: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+"/"+tt.b, func(t *testing.T) {
+			if got := editDistance(tt.a, tt.b, 10); got != tt.want {
+				t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEditDistance_Cutoff(t *testing.T) {
+	if got := editDistance("kubernetes", "wiki", 2); got != 3 {
+		t.Errorf("editDistance() = %d, want 3", got)
+	}
+	if got := editDistance("abcdef", "badcfe", 2); got != 3 {
+		t.Errorf("editDistance() = %d, want 3", got)
+	}
+}
+
+// TestEditDistance_MatchesReference compares editDistance with a plain
+// implementation of the same recurrence on random strings.
+func TestEditDistance_MatchesReference(t *testing.T) {
+	alphabet := []rune("abcé日-")
+	rng := rand.New(rand.NewPCG(1, 2))
+	randomString := func() string {
+		runes := make([]rune, rng.IntN(8))
+		for i := range runes {
+			runes[i] = alphabet[rng.IntN(len(alphabet))]
+		}
+		return string(runes)
+	}
+
+	for i := 0; i < 5000; i++ {
+		a, b := randomString(), randomString()
+		maxDistance := rng.IntN(6)
+		want := min(referenceDistance(a, b), maxDistance+1)
+
+		if got := editDistance(a, b, maxDistance); got != want {
+			t.Fatalf("editDistance(%q, %q, %d) = %d, want %d", a, b, maxDistance, got, want)
+		}
+		if got := editDistance(b, a, maxDistance); got != want {
+			t.Fatalf("editDistance(%q, %q, %d) = %d, not symmetric", b, a, maxDistance, got)
+		}
+		if got := editDistance(a, a, maxDistance); got != 0 {
+			t.Fatalf("editDistance(%q, %q, %d) = %d, want 0", a, a, maxDistance, got)
+		}
+	}
+}
+
+// TestDamerauLevenshtein_Bounds checks the properties the BK-tree relies on:
+// the distance is a lower bound for editDistance and obeys the triangle
+// inequality.
+func TestDamerauLevenshtein_Bounds(t *testing.T) {
+	if got := damerauLevenshtein([]rune("ca"), []rune("abc")); got != 2 {
+		t.Errorf("damerauLevenshtein(ca, abc) = %d, want 2", got)
+	}
+
+	alphabet := []rune("abcé日")
+	rng := rand.New(rand.NewPCG(7, 8))
+	randomRunes := func() []rune {
+		runes := make([]rune, rng.IntN(7))
+		for i := range runes {
+			runes[i] = alphabet[rng.IntN(len(alphabet))]
+		}
+		return runes
+	}
+
+	for i := 0; i < 5000; i++ {
+		a, b, c := randomRunes(), randomRunes(), randomRunes()
+		ab := damerauLevenshtein(a, b)
+		if osa := referenceDistance(string(a), string(b)); ab > osa {
+			t.Fatalf("damerauLevenshtein(%q, %q) = %d, larger than %d", string(a), string(b), ab, osa)
+		}
+		if ba := damerauLevenshtein(b, a); ab != ba {
+			t.Fatalf("damerauLevenshtein(%q, %q) is not symmetric: %d != %d", string(a), string(b), ab, ba)
+		}
+		if ac, cb := damerauLevenshtein(a, c), damerauLevenshtein(c, b); ab > ac+cb {
+			t.Fatalf("triangle inequality fails for %q, %q, %q", string(a), string(b), string(c))
+		}
+	}
+}
+
+// referenceDistance is the textbook optimal string alignment distance, built
+// from the full matrix without any cutoff.
+func referenceDistance(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	d := make([][]int, len(s)+1)
+	for i := range d {
+		d[i] = make([]int, len(t)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+
+	for i := 1; i <= len(s); i++ {
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && s[i-1] == t[j-2] && s[i-2] == t[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(s)][len(t)]
+}
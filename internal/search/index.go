@@ -0,0 +1,369 @@
+package search
+
+import (
+	"slices"
+	"unicode/utf8"
+)
+
+// Index is a searchable set of documents that can be updated in place. It
+// returns the same results as Search, without comparing the query to every
+// document:
+//
+//   - exact, prefix and substring matches are found through posting lists of
+//     the two and three rune substrings of every field, and
+//   - fuzzy matches are found through BK-trees of the paths and path words.
+//
+// Single rune terms match too much of any realistic link set to be worth
+// indexing, so they fall back to scoring every document.
+//
+// An Index is not safe for concurrent use; readers may share it as long as
+// nothing writes to it at the same time.
+type Index struct {
+	normalize func(string) string
+	nextID    int32
+	ids       map[string]int32
+	docs      map[int32]*indexedDoc
+	// postings maps each n-gram to the sorted ids of the documents containing
+	// it.
+	postings map[string][]int32
+	// words maps each path and path word to the documents containing it.
+	words map[string]map[int32]struct{}
+	// trees holds a BK-tree of the words of each length in runes, since only
+	// words within maxDistance of the query's length can match.
+	trees map[int]*bkNode
+	// dead holds the words that are still in a BK-tree after their last
+	// document was removed, since BK-trees do not support removal. The trees
+	// are rebuilt once there are more dead words than live ones.
+	dead map[string]struct{}
+}
+
+type indexedDoc struct {
+	key    string
+	fields fields
+	grams  []string
+	words  []string
+}
+
+// NewIndex returns an empty Index that normalizes paths and query terms with
+// normalize. A nil normalize compares them as-is.
+func NewIndex(normalize func(string) string) *Index {
+	if normalize == nil {
+		normalize = identity
+	}
+	return &Index{
+		normalize: normalize,
+		ids:       make(map[string]int32),
+		docs:      make(map[int32]*indexedDoc),
+		postings:  make(map[string][]int32),
+		words:     make(map[string]map[int32]struct{}),
+		trees:     make(map[int]*bkNode),
+		dead:      make(map[string]struct{}),
+	}
+}
+
+// Len returns the number of documents in the index.
+func (x *Index) Len() int {
+	return len(x.docs)
+}
+
+// Add indexes doc, replacing any document with the same key.
+func (x *Index) Add(doc Document) {
+	x.Remove(doc.Key)
+
+	// Ids only ever grow, so appending keeps the posting lists sorted.
+	id := x.nextID
+	x.nextID++
+
+	f := prepare(doc, x.normalize)
+	indexed := &indexedDoc{
+		key:    doc.Key,
+		fields: f,
+		grams:  f.grams(),
+		words:  f.words(),
+	}
+	x.ids[doc.Key] = id
+	x.docs[id] = indexed
+
+	for _, gram := range indexed.grams {
+		x.postings[gram] = append(x.postings[gram], id)
+	}
+	for _, word := range indexed.words {
+		docs, exists := x.words[word]
+		if !exists {
+			docs = make(map[int32]struct{})
+			x.words[word] = docs
+			if _, dead := x.dead[word]; dead {
+				delete(x.dead, word)
+			} else {
+				length := utf8.RuneCountInString(word)
+				x.trees[length] = x.trees[length].insert(word)
+			}
+		}
+		docs[id] = struct{}{}
+	}
+}
+
+// Remove drops the document with the given key, if there is one.
+func (x *Index) Remove(key string) {
+	id, exists := x.ids[key]
+	if !exists {
+		return
+	}
+	indexed := x.docs[id]
+	delete(x.ids, key)
+	delete(x.docs, id)
+
+	for _, gram := range indexed.grams {
+		posting := x.postings[gram]
+		if i, found := slices.BinarySearch(posting, id); found {
+			posting = slices.Delete(posting, i, i+1)
+		}
+		if len(posting) == 0 {
+			delete(x.postings, gram)
+		} else {
+			x.postings[gram] = posting
+		}
+	}
+	for _, word := range indexed.words {
+		docs := x.words[word]
+		delete(docs, id)
+		if len(docs) == 0 {
+			delete(x.words, word)
+			x.dead[word] = struct{}{}
+		}
+	}
+	if len(x.dead) > minDeadWords && len(x.dead) > len(x.words) {
+		x.pruneTrees()
+	}
+}
+
+// minDeadWords is the number of dead words below which the BK-trees are never
+// rebuilt, since rebuilding small trees often would cost more than it saves.
+const minDeadWords = 1024
+
+// pruneTrees rebuilds the BK-trees from the live words, dropping dead ones.
+func (x *Index) pruneTrees() {
+	x.trees = make(map[int]*bkNode)
+	for word := range x.words {
+		length := utf8.RuneCountInString(word)
+		x.trees[length] = x.trees[length].insert(word)
+	}
+	x.dead = make(map[string]struct{})
+}
+
+// Search returns the documents matching the query, best first, exactly as
+// Search would. opts.Normalize is ignored in favour of the index's own.
+func (x *Index) Search(query string, opts Options) []Result {
+	terms := parseQuery(query, x.normalize)
+	if len(terms) == 0 {
+		return []Result{}
+	}
+
+	var candidates map[int32]struct{}
+	for _, t := range terms {
+		termCandidates, all := x.candidates(t, opts.MaxDistance)
+		if all {
+			continue
+		}
+		if candidates == nil {
+			candidates = termCandidates
+		} else {
+			for id := range candidates {
+				if _, exists := termCandidates[id]; !exists {
+					delete(candidates, id)
+				}
+			}
+		}
+	}
+
+	results := make([]Result, 0)
+	score := func(indexed *indexedDoc) {
+		if score := indexed.fields.scoreAll(terms, opts.MaxDistance); score > 0 {
+			results = append(results, Result{Value: indexed.key, Score: score})
+		}
+	}
+	if candidates == nil {
+		for _, indexed := range x.docs {
+			score(indexed)
+		}
+	} else {
+		for id := range candidates {
+			score(x.docs[id])
+		}
+	}
+
+	return rank(results, opts)
+}
+
+// candidates returns the ids of the documents that may match t. If the term
+// is too short to narrow the documents down, it reports all instead.
+func (x *Index) candidates(t term, maxDistance int) (map[int32]struct{}, bool) {
+	candidates := make(map[int32]struct{})
+	for _, form := range []string{t.path, t.text} {
+		switch utf8.RuneCountInString(form) {
+		case 0:
+			continue
+		case 1:
+			return nil, true
+		}
+		for _, id := range x.containing(form) {
+			candidates[id] = struct{}{}
+		}
+	}
+
+	if maxDistance >= 0 && t.path != "" {
+		query := []rune(t.path)
+		for length := len(query) - maxDistance; length <= len(query)+maxDistance; length++ {
+			for _, word := range x.trees[length].within(query, maxDistance) {
+				for id := range x.words[word] {
+					candidates[id] = struct{}{}
+				}
+			}
+		}
+	}
+
+	return candidates, false
+}
+
+// containing returns the ids of the documents with every n-gram of s, which
+// includes every document with s as a substring of one of its fields.
+func (x *Index) containing(s string) []int32 {
+	n := 3
+	if utf8.RuneCountInString(s) < 3 {
+		n = 2
+	}
+
+	var ids []int32
+	for i, gram := range ngrams(s, n) {
+		posting := x.postings[gram]
+		if i == 0 {
+			ids = slices.Clone(posting)
+			continue
+		}
+		ids = intersect(ids, posting)
+		if len(ids) == 0 {
+			break
+		}
+	}
+	return ids
+}
+
+// grams returns the distinct two and three rune n-grams of every field that
+// can be matched as a substring.
+func (f fields) grams() []string {
+	sources := []string{f.path, f.target, f.description}
+	sources = append(sources, f.pathTokens...)
+	sources = append(sources, f.tags...)
+
+	var grams []string
+	for _, source := range sources {
+		grams = append(grams, ngrams(source, 2)...)
+		grams = append(grams, ngrams(source, 3)...)
+	}
+	slices.Sort(grams)
+	return slices.Compact(grams)
+}
+
+// words returns the distinct strings a term is compared to for fuzzy matches.
+func (f fields) words() []string {
+	words := append([]string{f.path}, f.pathTokens...)
+	words = slices.DeleteFunc(words, func(word string) bool { return word == "" })
+	slices.Sort(words)
+	return slices.Compact(words)
+}
+
+// ngrams returns the substrings of s that are n runes long.
+func ngrams(s string, n int) []string {
+	runes := []rune(s)
+	if len(runes) < n {
+		return nil
+	}
+	grams := make([]string, 0, len(runes)-n+1)
+	for i := 0; i+n <= len(runes); i++ {
+		grams = append(grams, string(runes[i:i+n]))
+	}
+	return grams
+}
+
+// intersect returns the ids present in both sorted lists.
+func intersect(a, b []int32) []int32 {
+	result := a[:0]
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			result = append(result, a[i])
+			i++
+			j++
+		}
+	}
+	return result
+}
+
+// bkNode is a node of a BK-tree, which finds the words within a given
+// Damerau-Levenshtein distance of a query without comparing it to every word.
+// Since that distance is never larger than the optimal string alignment
+// distance used for scoring, the tree finds every word that can score.
+type bkNode struct {
+	word     string
+	runes    []rune
+	children map[int]*bkNode
+}
+
+// insert adds word to the tree rooted at n and returns the root.
+func (n *bkNode) insert(word string) *bkNode {
+	runes := []rune(word)
+	if n == nil {
+		return &bkNode{word: word, runes: runes}
+	}
+
+	scratch := newDistanceScratch()
+	node := n
+	for {
+		d := scratch.damerauLevenshtein(runes, node.runes)
+		if d == 0 {
+			return n
+		}
+		child, exists := node.children[d]
+		if !exists {
+			if node.children == nil {
+				node.children = make(map[int]*bkNode)
+			}
+			node.children[d] = &bkNode{word: word, runes: runes}
+			return n
+		}
+		node = child
+	}
+}
+
+// within returns the words in the tree at most maxDistance edits from query.
+func (n *bkNode) within(query []rune, maxDistance int) []string {
+	if n == nil {
+		return nil
+	}
+
+	scratch := newDistanceScratch()
+	var words []string
+	stack := []*bkNode{n}
+	for len(stack) > 0 {
+		node := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		d := scratch.damerauLevenshtein(query, node.runes)
+		if d <= maxDistance {
+			words = append(words, node.word)
+		}
+		// By the triangle inequality, matches can only be below children
+		// whose distance to this node is within maxDistance of d.
+		for childDistance, child := range node.children {
+			if childDistance >= d-maxDistance && childDistance <= d+maxDistance {
+				stack = append(stack, child)
+			}
+		}
+	}
+	return words
+}
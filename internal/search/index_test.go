@@ -0,0 +1,212 @@
+package search
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var vocabulary = []string{
+	"kubernetes", "dashboard", "oncall", "wiki", "grafana", "deploy", "docs",
+	"café", "straße", "日本語", "metrics", "billing", "api", "status", "k8s",
+}
+
+func randomDocument(rng *rand.Rand, key string) Document {
+	word := func() string { return vocabulary[rng.IntN(len(vocabulary))] }
+	return Document{
+		Key:         key,
+		Path:        word() + "-" + word() + key,
+		Target:      fmt.Sprintf("https://%s.example.com/%s", word(), word()),
+		Description: word() + " " + word(),
+		Tags:        []string{word()},
+	}
+}
+
+// randomQuery returns a query that is a word, part of a word or a misspelled
+// word, or two of those.
+func randomQuery(rng *rand.Rand) string {
+	term := func() string {
+		runes := []rune(vocabulary[rng.IntN(len(vocabulary))])
+		switch rng.IntN(4) {
+		case 0:
+			start := rng.IntN(len(runes))
+			return string(runes[start : start+1+rng.IntN(len(runes)-start)])
+		case 1:
+			i := rng.IntN(len(runes))
+			runes[i] = 'x'
+		case 2:
+			if len(runes) > 1 {
+				i := rng.IntN(len(runes) - 1)
+				runes[i], runes[i+1] = runes[i+1], runes[i]
+			}
+		}
+		return string(runes)
+	}
+	if rng.IntN(3) == 0 {
+		return term() + " " + term()
+	}
+	return term()
+}
+
+func TestIndex_MatchesSearch(t *testing.T) {
+	rng := rand.New(rand.NewPCG(3, 4))
+	normalize := func(s string) string { return strings.ReplaceAll(strings.ToLower(s), "-", "") }
+	index := NewIndex(normalize)
+	docs := make(map[string]Document)
+	for i := 0; i < 500; i++ {
+		doc := randomDocument(rng, fmt.Sprint(i))
+		docs[doc.Key] = doc
+		index.Add(doc)
+	}
+
+	// Replace and remove some documents so that stale postings would show up.
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprint(rng.IntN(500))
+		if rng.IntN(2) == 0 {
+			doc := randomDocument(rng, key)
+			docs[key] = doc
+			index.Add(doc)
+		} else {
+			delete(docs, key)
+			index.Remove(key)
+		}
+	}
+	if index.Len() != len(docs) {
+		t.Fatalf("Len() = %d, want %d", index.Len(), len(docs))
+	}
+
+	all := make([]Document, 0, len(docs))
+	for _, doc := range docs {
+		all = append(all, doc)
+	}
+	for i := 0; i < 1000; i++ {
+		query := randomQuery(rng)
+		opts := Options{MaxDistance: rng.IntN(3), Limit: 1000, Normalize: normalize}
+		want := Search(query, all, opts)
+		got := index.Search(query, opts)
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("Index.Search(%q, %d) = %v, want %v", query, opts.MaxDistance, got, want)
+		}
+	}
+}
+
+func TestIndex_Update(t *testing.T) {
+	index := NewIndex(nil)
+	index.Add(Document{Key: "docs", Path: "docs", Target: "https://docs.example.com"})
+
+	index.Add(Document{Key: "docs", Path: "docs", Target: "https://wiki.example.com"})
+	if got := index.Search("wiki", DefaultOptions()); len(got) != 1 || got[0].Value != "docs" {
+		t.Errorf("Search(wiki) = %v, want [docs]", got)
+	}
+	if got := index.Search("docs.example", DefaultOptions()); len(got) != 0 {
+		t.Errorf("Search(docs.example) = %v, want no results after replacing the target", got)
+	}
+
+	index.Remove("docs")
+	if got := index.Search("docs", DefaultOptions()); len(got) != 0 {
+		t.Errorf("Search(docs) = %v, want no results after removal", got)
+	}
+}
+
+func TestIndex_PrunesRemovedWords(t *testing.T) {
+	index := NewIndex(nil)
+	for i := 0; i < 10*minDeadWords; i++ {
+		key := fmt.Sprintf("renamed%d", i)
+		index.Add(Document{Key: key, Path: key})
+		index.Remove(key)
+	}
+	index.Add(Document{Key: "kubernetes", Path: "kubernetes"})
+
+	words := 0
+	for _, tree := range index.trees {
+		words += tree.size()
+	}
+	if limit := 2*minDeadWords + 1; words > limit {
+		t.Errorf("BK-trees hold %d words after renames, want at most %d", words, limit)
+	}
+	if got := index.Search("kubernets", DefaultOptions()); len(got) != 1 || got[0].Value != "kubernetes" {
+		t.Errorf("Search(kubernets) = %v, want [kubernetes]", got)
+	}
+}
+
+// size returns the number of words in the tree rooted at n.
+func (n *bkNode) size() int {
+	if n == nil {
+		return 0
+	}
+	size := 1
+	for _, child := range n.children {
+		size += child.size()
+	}
+	return size
+}
+
+const benchmarkLinks = 100_000
+
+// benchmarkDocuments returns links made of words from a vocabulary large enough
+// that, as in a real link set, most queries match only a few links.
+func benchmarkDocuments() []Document {
+	rng := rand.New(rand.NewPCG(5, 6))
+	vocabulary := make([]string, 20_000)
+	for i := range vocabulary {
+		word := make([]rune, 4+rng.IntN(7))
+		for j := range word {
+			word[j] = rune('a' + rng.IntN(26))
+		}
+		vocabulary[i] = string(word)
+	}
+	vocabulary[0], vocabulary[1], vocabulary[2] = "kubernetes", "dashboard", "grafana"
+
+	word := func() string { return vocabulary[rng.IntN(len(vocabulary))] }
+	docs := make([]Document, benchmarkLinks)
+	for i := range docs {
+		docs[i] = Document{
+			Key:         fmt.Sprint(i),
+			Path:        word() + "-" + word(),
+			Target:      fmt.Sprintf("https://%s.example.com/%s", word(), word()),
+			Description: word() + " " + word() + " " + word(),
+			Tags:        []string{word()},
+		}
+	}
+	return docs
+}
+
+var benchmarkQueries = map[string]string{
+	"prefix":    "kube",
+	"typo":      "dashbaord",
+	"domain":    "grafana.example",
+	"two terms": "kubernetes dashboard",
+	"no match":  "qqqqqq",
+}
+
+func BenchmarkSearch(b *testing.B) {
+	docs := benchmarkDocuments()
+	index := NewIndex(nil)
+	for _, doc := range docs {
+		index.Add(doc)
+	}
+
+	for name, query := range benchmarkQueries {
+		b.Run("linear/"+name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				Search(query, docs, DefaultOptions())
+			}
+		})
+		b.Run("index/"+name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				index.Search(query, DefaultOptions())
+			}
+		})
+	}
+}
+
+func BenchmarkIndex_Add(b *testing.B) {
+	index := NewIndex(nil)
+	docs := benchmarkDocuments()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		index.Add(docs[i%len(docs)])
+	}
+}
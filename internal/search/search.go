@@ -1,80 +1,278 @@
 package search
 
-import "slices"
+import (
+	"cmp"
+	"net/url"
+	"slices"
+	"strings"
+	"unicode"
+)
 
-const shortcutEditThreshold = 3
+const (
+	// DefaultMaxDistance is the default largest edit distance at which a path
+	// still counts as a fuzzy match.
+	DefaultMaxDistance = 2
+	// DefaultLimit is the default number of results returned.
+	DefaultLimit = 20
+)
+
+// Ranking weights. A document scores the best of its field matches for each
+// query term, and the scores of the terms are added up, so a match on the path
+// always outranks the same kind of match on the other fields.
+const (
+	weightPathExact       = 100
+	weightPathPrefix      = 80
+	weightPathTokenPrefix = 60
+	weightPathSubstring   = 50
+	weightPathFuzzy       = 40
+	weightFuzzyPenalty    = 10
+	weightTagExact        = 45
+	weightTagPrefix       = 35
+	weightHostTokenPrefix = 30
+	weightTextTokenPrefix = 25
+	weightTextSubstring   = 20
+	weightTargetSubstring = 15
+)
 
 type Result struct {
 	Value string
 	Score int
 }
 
-func StringSearch(query string, options []string) []Result {
-	results := make([]Result, len(options))
-	for i, val := range options {
-		score := computeLevenshtein(query, val)
-		result := Result{
-			Value: val,
-			Score: score,
+// Document is a link as seen by search. Key is returned in results and is
+// usually the path as it was written.
+type Document struct {
+	Key         string
+	Path        string
+	Target      string
+	Description string
+	Tags        []string
+}
+
+// Options tunes a search.
+type Options struct {
+	// MaxDistance is the largest edit distance between a query term and a path
+	// that is still a match. Negative disables fuzzy matching.
+	MaxDistance int
+	// Limit caps the number of results. Zero or less uses DefaultLimit.
+	Limit int
+	// Normalize is applied to paths and query terms before they are compared
+	// to paths, so that matching agrees with how links are looked up. Nil
+	// compares them as-is. An Index normalizes with its own function instead.
+	Normalize func(string) string
+	// Filter, if set, drops the results for which it returns false before the
+	// limit is applied.
+	Filter func(key string) bool
+	// Popularity, if set, orders results with equal scores, most popular
+	// first. It never lifts a worse match above a better one.
+	Popularity func(key string) float64
+}
+
+// DefaultOptions returns the default search options.
+func DefaultOptions() Options {
+	return Options{MaxDistance: DefaultMaxDistance, Limit: DefaultLimit}
+}
+
+// Search scores each document against the query and returns the matches, best
+// first. Every whitespace separated term of the query has to match the path,
+// target, description or tags of a document for it to be returned. Equal
+// scores are ordered by popularity, then by key, shortest first.
+//
+// Search compares the query with every document. Use an Index to search large
+// sets of documents repeatedly.
+func Search(query string, docs []Document, opts Options) []Result {
+	normalize := opts.Normalize
+	if normalize == nil {
+		normalize = identity
+	}
+	terms := parseQuery(query, normalize)
+	if len(terms) == 0 {
+		return []Result{}
+	}
+
+	results := make([]Result, 0)
+	for _, doc := range docs {
+		if score := prepare(doc, normalize).scoreAll(terms, opts.MaxDistance); score > 0 {
+			results = append(results, Result{Value: doc.Key, Score: score})
 		}
-		results[i] = result
 	}
 
-	slices.SortStableFunc(results, func(a, b Result) int {
-		return a.Score - b.Score // Lower scores are closer matches
-	})
+	return rank(results, opts)
+}
 
-	for i, result := range results {
-		if result.Score > shortcutEditThreshold {
-			return results[:i]
+func identity(s string) string {
+	return s
+}
+
+func parseQuery(query string, normalize func(string) string) []term {
+	fields := strings.Fields(query)
+	terms := make([]term, len(fields))
+	for i, t := range fields {
+		terms[i] = term{path: normalize(t), text: strings.ToLower(t)}
+	}
+	return terms
+}
+
+// rank filters, sorts and limits results.
+func rank(results []Result, opts Options) []Result {
+	if opts.Filter != nil {
+		results = slices.DeleteFunc(results, func(result Result) bool {
+			return !opts.Filter(result.Value)
+		})
+	}
+
+	popularity := make(map[string]float64)
+	if opts.Popularity != nil {
+		for _, result := range results {
+			popularity[result.Value] = opts.Popularity(result.Value)
 		}
 	}
 
+	slices.SortFunc(results, func(a, b Result) int {
+		return cmp.Or(
+			cmp.Compare(b.Score, a.Score),
+			cmp.Compare(popularity[b.Value], popularity[a.Value]),
+			cmp.Compare(len(a.Value), len(b.Value)),
+			strings.Compare(a.Value, b.Value),
+		)
+	})
+
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	if len(results) > limit {
+		results = results[:limit]
+	}
 	return results
 }
 
-func computeLevenshtein(query, value string) int {
-	queryLen := len(query)
-	valueLen := len(value)
-	distanceMatrix := make([][]int, queryLen)
-	for i := 0; i < queryLen; i++ {
-		distanceMatrix[i] = make([]int, valueLen)
+// term is a single query term, prepared for comparison with paths and with
+// the other fields.
+type term struct {
+	path string
+	text string
+}
+
+// fields holds the searchable parts of a document in comparable form.
+type fields struct {
+	path        string
+	pathTokens  []string
+	hostTokens  []string
+	target      string
+	description string
+	textTokens  []string
+	tags        []string
+}
+
+func prepare(doc Document, normalize func(string) string) fields {
+	target := strings.ToLower(doc.Target)
+	description := strings.ToLower(doc.Description)
+
+	var hostTokens []string
+	if parsed, err := url.Parse(target); err == nil {
+		hostTokens = strings.Split(parsed.Hostname(), ".")
+	}
+
+	pathTokens := tokenize(doc.Path)
+	for i, token := range pathTokens {
+		pathTokens[i] = normalize(token)
+	}
+
+	tags := make([]string, len(doc.Tags))
+	for i, tag := range doc.Tags {
+		tags[i] = strings.ToLower(tag)
 	}
 
-	// Match empty string by dropping all characters
-	for i := 0; i < queryLen; i++ {
-		distanceMatrix[i][0] = i
+	return fields{
+		path:        normalize(doc.Path),
+		pathTokens:  pathTokens,
+		hostTokens:  hostTokens,
+		target:      target,
+		description: description,
+		textTokens:  tokenize(description),
+		tags:        tags,
 	}
+}
 
-	for j := 0; j < valueLen; j++ {
-		distanceMatrix[0][j] = j
+// scoreAll returns the sum of the scores of the terms, or zero if any of them
+// does not match.
+func (f fields) scoreAll(terms []term, maxDistance int) int {
+	total := 0
+	for _, t := range terms {
+		score := f.score(t, maxDistance)
+		if score == 0 {
+			return 0
+		}
+		total += score
 	}
+	return total
+}
 
-	for j := 1; j < valueLen; j++ {
-		for i := 1; i < queryLen; i++ {
-			cost := 0
-			if query[i] != value[j] {
-				cost = 1
+// score returns the best score of any field for the term, or zero if nothing
+// matches.
+func (f fields) score(t term, maxDistance int) int {
+	switch {
+	case t.path != "" && f.path == t.path:
+		return weightPathExact
+	case t.path != "" && strings.HasPrefix(f.path, t.path):
+		return weightPathPrefix
+	case t.path != "" && hasTokenWithPrefix(f.pathTokens, t.path):
+		return weightPathTokenPrefix
+	case t.path != "" && strings.Contains(f.path, t.path):
+		return weightPathSubstring
+	}
+
+	best := 0
+	if maxDistance >= 0 && t.path != "" {
+		for _, candidate := range append([]string{f.path}, f.pathTokens...) {
+			if candidate == "" {
+				continue
+			}
+			if distance := editDistance(t.path, candidate, maxDistance); distance <= maxDistance {
+				best = max(best, weightPathFuzzy-weightFuzzyPenalty*distance)
 			}
+		}
+	}
 
-			distanceMatrix[i][j] = minOf(
-				distanceMatrix[i-1][j]+1,
-				distanceMatrix[i][j-1]+1,
-				distanceMatrix[i-1][j-1]+cost,
-			)
+	for _, tag := range f.tags {
+		switch {
+		case tag == t.text:
+			best = max(best, weightTagExact)
+		case strings.HasPrefix(tag, t.text):
+			best = max(best, weightTagPrefix)
 		}
 	}
 
-	return distanceMatrix[queryLen-1][valueLen-1]
+	switch {
+	case hasTokenWithPrefix(f.hostTokens, t.text):
+		best = max(best, weightHostTokenPrefix)
+	case strings.Contains(f.target, t.text):
+		best = max(best, weightTargetSubstring)
+	}
+
+	switch {
+	case hasTokenWithPrefix(f.textTokens, t.text):
+		best = max(best, weightTextTokenPrefix)
+	case strings.Contains(f.description, t.text):
+		best = max(best, weightTextSubstring)
+	}
+
+	return best
 }
 
-func minOf(vars ...int) int {
-	m := vars[0]
+// tokenize splits s into words at anything that is not a letter or a number.
+func tokenize(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
 
-	for _, i := range vars {
-		if m > i {
-			m = i
+func hasTokenWithPrefix(tokens []string, prefix string) bool {
+	for _, token := range tokens {
+		if strings.HasPrefix(token, prefix) {
+			return true
 		}
 	}
-	return m
+	return false
 }
@@ -0,0 +1,83 @@
+package search
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var testDocs = []Document{
+	{Key: "kubernetes-dashboard", Path: "kubernetes-dashboard", Target: "https://k8s.example.com/dashboard"},
+	{Key: "kubectl", Path: "kubectl", Target: "https://kubernetes.io/docs/reference/kubectl"},
+	{Key: "dashboard", Path: "dashboard", Target: "https://grafana.example.com"},
+	{Key: "oncall", Path: "oncall", Target: "https://pagerduty.com/schedules", Description: "Who is on call this week", Tags: []string{"sre", "pager"}},
+	{Key: "wiki", Path: "wiki", Target: "https://confluence.example.com", Description: "Engineering wiki"},
+}
+
+func keys(results []Result) []string {
+	keys := make([]string, len(results))
+	for i, result := range results {
+		keys[i] = result.Value
+	}
+	return keys
+}
+
+func TestSearch(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{name: "Matches path prefixes", query: "kube", want: []string{"kubectl", "kubernetes-dashboard"}},
+		{name: "Ranks exact matches first", query: "dashboard", want: []string{"dashboard", "kubernetes-dashboard"}},
+		{name: "Matches words inside paths", query: "dash", want: []string{"dashboard", "kubernetes-dashboard"}},
+		{name: "Matches target domains", query: "grafana", want: []string{"dashboard"}},
+		{name: "Matches descriptions", query: "engineering", want: []string{"wiki"}},
+		{name: "Matches tags", query: "SRE", want: []string{"oncall"}},
+		{name: "Requires every term to match", query: "kube dashboard", want: []string{"kubernetes-dashboard"}},
+		{name: "Matches misspelled paths", query: "wikk", want: []string{"wiki"}},
+		{name: "Returns nothing for an empty query", query: "  ", want: []string{}},
+		{name: "Returns nothing without a match", query: "zzz", want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := keys(Search(tt.query, testDocs, DefaultOptions()))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Search(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearch_Options(t *testing.T) {
+	t.Run("Limits the number of results", func(t *testing.T) {
+		got := Search("kube", testDocs, Options{Limit: 1})
+		if len(got) != 1 {
+			t.Errorf("got %d results, want 1", len(got))
+		}
+	})
+
+	t.Run("Disables fuzzy matching", func(t *testing.T) {
+		got := Search("wikk", testDocs, Options{MaxDistance: -1})
+		if len(got) != 0 {
+			t.Errorf("got %v, want no results", keys(got))
+		}
+	})
+
+	t.Run("Normalizes paths and terms", func(t *testing.T) {
+		normalize := func(s string) string { return strings.ReplaceAll(strings.ToLower(s), "-", "") }
+		got := keys(Search("Kubernetes-Dash", testDocs, Options{Normalize: normalize}))
+		if !reflect.DeepEqual(got, []string{"kubernetes-dashboard"}) {
+			t.Errorf("got %v, want [kubernetes-dashboard]", got)
+		}
+	})
+
+	t.Run("Orders equal scores by popularity", func(t *testing.T) {
+		docs := []Document{{Key: "dash-a", Path: "dash-a"}, {Key: "dash-b", Path: "dash-b"}, {Key: "dash", Path: "dash"}}
+		popularity := map[string]float64{"dash-b": 10, "dash": 1000}
+		got := keys(Search("dash", docs, Options{Popularity: func(key string) float64 { return popularity[key] }}))
+		if !reflect.DeepEqual(got, []string{"dash", "dash-b", "dash-a"}) {
+			t.Errorf("got %v, want [dash dash-b dash-a]", got)
+		}
+	})
+}
@@ -0,0 +1,73 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	mjolnir "github.com/dfryer1193/mjolnir/middleware"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/rs/zerolog"
+)
+
+type accessLogKey struct{}
+
+// accessLogEntry holds what handlers add to the access log line of a request.
+type accessLogEntry struct {
+	link   string
+	target string
+}
+
+// SetLink records the link a request resolved and the target it was sent to,
+// for the access log.
+func SetLink(r *http.Request, link string, target string) {
+	if entry, ok := r.Context().Value(accessLogKey{}).(*accessLogEntry); ok {
+		entry.link = link
+		entry.target = target
+	}
+}
+
+// AccessLog returns middleware that logs a line for every request through
+// logger. It also adds a logger carrying the request ID to the request
+// context, for handlers to log with through zerolog.Ctx.
+//
+// Successful redirects make up most of the traffic of a link shortener, so
+// only one in redirectSampleRate of them is logged. Everything else, failed
+// redirects included, is always logged.
+func AccessLog(logger zerolog.Logger, redirectSampleRate int) func(http.Handler) http.Handler {
+	var redirects atomic.Uint64
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			requestLogger := logger.With().Str("request_id", mjolnir.GetRequestID(r.Context())).Logger()
+			entry := &accessLogEntry{}
+			ctx := context.WithValue(requestLogger.WithContext(r.Context()), accessLogKey{}, entry)
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r.WithContext(ctx))
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			if entry.link != "" && status < http.StatusBadRequest && redirectSampleRate > 1 {
+				if redirects.Add(1)%uint64(redirectSampleRate) != 1 {
+					return
+				}
+			}
+
+			event := requestLogger.Info().
+				Str("method", r.Method).
+				Str("path", r.URL.Path).
+				Str("remote_ip", remoteIP(r)).
+				Int("status", status).
+				Int("bytes", ww.BytesWritten()).
+				Dur("latency", time.Since(start))
+			if entry.link != "" {
+				event = event.Str("link", entry.link).Str("target", entry.target)
+			}
+			event.Msg("request completed")
+		})
+	}
+}
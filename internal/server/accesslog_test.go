@@ -0,0 +1,114 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	mjolnir "github.com/dfryer1193/mjolnir/middleware"
+	"github.com/rs/zerolog"
+)
+
+func accessLogLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var lines []map[string]any
+	sc := bufio.NewScanner(buf)
+	for sc.Scan() {
+		var line map[string]any
+		if err := json.Unmarshal(sc.Bytes(), &line); err != nil {
+			t.Fatalf("invalid log line %q: %v", sc.Text(), err)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	handler := mjolnir.RequestID(AccessLog(zerolog.New(&buf), 1)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		zerolog.Ctx(r.Context()).Warn().Msg("from the handler")
+		SetLink(r, "docs", "https://docs.example.com")
+		http.Redirect(w, r, "https://docs.example.com", http.StatusTemporaryRedirect)
+	})))
+
+	req := httptest.NewRequest(http.MethodGet, "/docs", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	req.Header.Set("X-Request-ID", "abc")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	lines := accessLogLines(t, &buf)
+	if len(lines) != 2 {
+		t.Fatalf("got %d log lines, want 2: %v", len(lines), lines)
+	}
+	if got := lines[0]["request_id"]; got != "abc" {
+		t.Errorf("handler log request_id = %v, want abc", got)
+	}
+	want := map[string]any{
+		"request_id": "abc",
+		"method":     "GET",
+		"path":       "/docs",
+		"remote_ip":  "192.0.2.1",
+		"status":     float64(http.StatusTemporaryRedirect),
+		"link":       "docs",
+		"target":     "https://docs.example.com",
+	}
+	for key, value := range want {
+		if lines[1][key] != value {
+			t.Errorf("access log %s = %v, want %v", key, lines[1][key], value)
+		}
+	}
+	if _, ok := lines[1]["latency"]; !ok {
+		t.Error("access log has no latency")
+	}
+}
+
+func TestAccessLog_SamplesRedirects(t *testing.T) {
+	var buf bytes.Buffer
+	handler := AccessLog(zerolog.New(&buf), 3)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		SetLink(r, "docs", "https://docs.example.com")
+		w.WriteHeader(http.StatusTemporaryRedirect)
+	}))
+
+	for range 6 {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/docs", nil))
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/missing", nil))
+	}
+
+	counts := make(map[string]int)
+	for _, line := range accessLogLines(t, &buf) {
+		counts[line["path"].(string)]++
+	}
+	if counts["/docs"] != 2 || counts["/missing"] != 6 {
+		t.Errorf("logged %d redirects and %d failures, want 2 and 6", counts["/docs"], counts["/missing"])
+	}
+}
+
+func TestAccessLog_UnixSocket(t *testing.T) {
+	var buf bytes.Buffer
+	client := serveUnix(t, RealIP(nil, true)(AccessLog(zerolog.New(&buf), 1)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))))
+
+	req, _ := http.NewRequest(http.MethodGet, "http://go/docs", nil)
+	req.Header.Set("X-Forwarded-For", "203.0.113.9")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	lines := accessLogLines(t, &buf)
+	if len(lines) != 1 {
+		t.Fatalf("got %d log lines, want 1: %v", len(lines), lines)
+	}
+	if got := lines[0]["remote_ip"]; got != "203.0.113.9" {
+		t.Errorf("access log remote_ip = %v, want 203.0.113.9", got)
+	}
+}
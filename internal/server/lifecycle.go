@@ -0,0 +1,58 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/rs/zerolog/log"
+)
+
+// stopHook is a step of shutting down.
+type stopHook struct {
+	name string
+	stop func(context.Context) error
+}
+
+// Lifecycle runs the steps of shutting down in the order they were added, once
+// the process is asked to stop.
+type Lifecycle struct {
+	hooks []stopHook
+}
+
+// NewLifecycle returns a Lifecycle with no shutdown steps.
+func NewLifecycle() *Lifecycle {
+	return &Lifecycle{}
+}
+
+// OnStop adds a step to shutting down. Steps run in the order they were
+// added, so that, for instance, servers stop taking requests before the writes
+// they caused are waited for.
+func (lc *Lifecycle) OnStop(name string, stop func(context.Context) error) {
+	lc.hooks = append(lc.hooks, stopHook{name: name, stop: stop})
+}
+
+// Wait blocks until the process receives SIGINT or SIGTERM, which is what
+// Docker and systemd send to stop it.
+func (lc *Lifecycle) Wait() os.Signal {
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(quit)
+	return <-quit
+}
+
+// Stop runs every shutdown step, even if an earlier one failed, and returns
+// the errors of those that did. Steps share the deadline of ctx.
+func (lc *Lifecycle) Stop(ctx context.Context) error {
+	var errs []error
+	for _, hook := range lc.hooks {
+		log.Debug().Str("step", hook.name).Msg("Stopping")
+		if err := hook.stop(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", hook.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,73 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dfryer1193/golinks/internal/links"
+	"github.com/dfryer1193/golinks/internal/links/storage"
+	"github.com/dfryer1193/golinks/models"
+)
+
+func TestLifecycle_StopSavesWrites(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "links")
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	linkMap := links.NewLinkMap(storage.FILE, path)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /docs", func(w http.ResponseWriter, r *http.Request) {
+		if err := linkMap.Put(r.Context(), &models.Entry{Path: "docs", Target: "https://docs.com"}); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		linkMap.RecordClick("docs")
+		w.WriteHeader(http.StatusNoContent)
+	})
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: mux}
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve(listener) }()
+
+	lifecycle := NewLifecycle()
+	lifecycle.OnStop("server", srv.Shutdown)
+	lifecycle.OnStop("links", linkMap.Close)
+
+	resp, err := http.Post("http://"+listener.Addr().String()+"/docs", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("POST status = %d, want %d", resp.StatusCode, http.StatusNoContent)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := lifecycle.Stop(ctx); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	if err := <-served; !errors.Is(err, http.ErrServerClosed) {
+		t.Errorf("Serve() error = %v, want %v", err, http.ErrServerClosed)
+	}
+
+	reopened := links.NewLinkMap(storage.FILE, path)
+	defer reopened.Close(context.Background())
+	entry, exists := reopened.GetEntry("docs")
+	if !exists {
+		t.Fatal("the link written before shutting down was not saved")
+	}
+	if entry.Clicks != 1 {
+		t.Errorf("clicks = %d, want the click recorded before shutting down", entry.Clicks)
+	}
+}
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestUnixListener(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "golinks.sock")
+
+	listener, err := UnixListener(path, 0600)
+	if err != nil {
+		t.Fatalf("UnixListener() error = %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0600 {
+		t.Errorf("socket mode = %o, want 600", mode)
+	}
+
+	// A socket left behind by a run that did not clean up is replaced.
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	listener.Close()
+	listener, err = UnixListener(path, 0600)
+	if err != nil {
+		t.Fatalf("UnixListener() over a stale socket error = %v", err)
+	}
+	listener.Close()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("socket still exists after Close: %v", err)
+	}
+}
+
+func TestUnixListener_RefusesOtherFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "links")
+	if err := os.WriteFile(path, []byte("keep me"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := UnixListener(path, 0600); err == nil {
+		t.Error("UnixListener() error = nil, want an error")
+	}
+	if data, _ := os.ReadFile(path); string(data) != "keep me" {
+		t.Errorf("file was replaced with %q", data)
+	}
+}
+
+func TestSystemdListeners_NotActivated(t *testing.T) {
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()+1))
+	t.Setenv("LISTEN_FDS", "1")
+
+	listeners, err := SystemdListeners()
+	if err != nil || listeners != nil {
+		t.Errorf("SystemdListeners() = %v, %v, want no listeners for another process", listeners, err)
+	}
+	if _, set := os.LookupEnv("LISTEN_FDS"); set {
+		t.Error("LISTEN_FDS is still set")
+	}
+}
+
+// serveUnix serves handler on a Unix domain socket the way golinks does, and
+// returns a client whose requests are made over it.
+func serveUnix(t *testing.T, handler http.Handler) *http.Client {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "golinks.sock")
+	listener, err := UnixListener(path, 0600)
+	if err != nil {
+		t.Fatalf("UnixListener() error = %v", err)
+	}
+	srv := &http.Server{Handler: handler, ConnContext: ConnContext}
+	go srv.Serve(listener)
+	t.Cleanup(func() { srv.Close() })
+
+	return &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", path)
+		},
+	}}
+}
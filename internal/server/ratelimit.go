@@ -0,0 +1,190 @@
+package server
+
+import (
+	"errors"
+	"expvar"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	mjolnir "github.com/dfryer1193/mjolnir/middleware"
+)
+
+// rateLimitStats publishes, for each rate limiter, how many requests it let
+// through and turned away, and how many clients it is tracking.
+var rateLimitStats = expvar.NewMap("rate_limit")
+
+var errRateLimited = errors.New("too many requests, try again later")
+
+// Limit allows Requests requests every Period, in bursts of up to Requests.
+// The zero Limit allows everything.
+type Limit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// ParseLimit parses a limit written as requests per period, such as "10/s",
+// "600/m" or "5/30s". An empty string or "0" means no limit.
+func ParseLimit(s string) (Limit, error) {
+	if s == "" || s == "0" {
+		return Limit{}, nil
+	}
+
+	rawRequests, rawPeriod, found := strings.Cut(s, "/")
+	if !found {
+		return Limit{}, fmt.Errorf("%q is not of the form <requests>/<period>", s)
+	}
+	requests, err := strconv.Atoi(rawRequests)
+	if err != nil || requests <= 0 {
+		return Limit{}, fmt.Errorf("%q is not a positive number of requests", rawRequests)
+	}
+	if rawPeriod != "" && !strings.ContainsAny(rawPeriod[:1], "0123456789") {
+		rawPeriod = "1" + rawPeriod
+	}
+	period, err := time.ParseDuration(rawPeriod)
+	if err != nil || period <= 0 {
+		return Limit{}, fmt.Errorf("%q is not a positive period", rawPeriod)
+	}
+	return Limit{Requests: requests, Period: period}, nil
+}
+
+// Enabled reports whether the limit restricts anything.
+func (l Limit) Enabled() bool {
+	return l.Requests > 0 && l.Period > 0
+}
+
+func (l Limit) String() string {
+	if !l.Enabled() {
+		return "0"
+	}
+	return fmt.Sprintf("%d/%s", l.Requests, l.Period)
+}
+
+// bucket holds the tokens left to a client as of updated.
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// RateLimiter limits requests with a token bucket for each client address and
+// each API token. A request made with a token draws from both, so a script
+// keeps to the budget of its token wherever it runs from, and sending made-up
+// tokens does not get a client past the budget of its address.
+type RateLimiter struct {
+	name      string
+	limit     Limit
+	now       func() time.Time
+	lock      sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// NewRateLimiter returns a rate limiter that applies limit, and reports its
+// decisions under name in the "rate_limit" expvar.
+func NewRateLimiter(name string, limit Limit) *RateLimiter {
+	rl := &RateLimiter{
+		name:    name,
+		limit:   limit,
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+	}
+	rateLimitStats.Set(name+"_clients", expvar.Func(func() any {
+		rl.lock.Lock()
+		defer rl.lock.Unlock()
+		return len(rl.buckets)
+	}))
+	return rl
+}
+
+// Middleware returns middleware that answers requests over the limit with 429
+// Too Many Requests and a Retry-After header. A nil RateLimiter, or one with
+// no limit, lets every request through.
+func (rl *RateLimiter) Middleware(next http.Handler) http.Handler {
+	if rl == nil || !rl.limit.Enabled() {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys := []string{"ip:" + remoteIP(r)}
+		if token := bearerToken(r); token != "" {
+			keys = append(keys, "token:"+token)
+		}
+
+		if wait, allowed := rl.take(keys); !allowed {
+			rateLimitStats.Add(rl.name+"_rejected", 1)
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			mjolnir.SetError(r, http.StatusTooManyRequests, errRateLimited)
+			return
+		}
+
+		rateLimitStats.Add(rl.name+"_allowed", 1)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// take draws a token from the bucket of every key, if they all have one.
+// Otherwise it draws nothing and returns how long until they all will. Buckets
+// are only kept for keys whose requests were allowed, so that rejected requests
+// with made-up tokens cannot fill the limiter with clients.
+func (rl *RateLimiter) take(keys []string) (time.Duration, bool) {
+	rl.lock.Lock()
+	defer rl.lock.Unlock()
+
+	now := rl.now()
+	rl.sweep(now)
+
+	capacity := float64(rl.limit.Requests)
+	perSecond := capacity / rl.limit.Period.Seconds()
+	allowed := true
+	var wait time.Duration
+	buckets := make([]*bucket, len(keys))
+	for i, key := range keys {
+		b, exists := rl.buckets[key]
+		if !exists {
+			b = &bucket{tokens: capacity, updated: now}
+		}
+		b.tokens = min(capacity, b.tokens+now.Sub(b.updated).Seconds()*perSecond)
+		b.updated = now
+		if b.tokens < 1 {
+			allowed = false
+			wait = max(wait, time.Duration((1-b.tokens)/perSecond*float64(time.Second)))
+		}
+		buckets[i] = b
+	}
+	if !allowed {
+		return wait, false
+	}
+
+	for i, b := range buckets {
+		b.tokens--
+		rl.buckets[keys[i]] = b
+	}
+	return 0, true
+}
+
+// sweep forgets the clients whose buckets have refilled, since they are no
+// different from a client that was never seen. Callers must hold lock.
+func (rl *RateLimiter) sweep(now time.Time) {
+	if now.Sub(rl.lastSweep) < rl.limit.Period {
+		return
+	}
+	rl.lastSweep = now
+	for key, b := range rl.buckets {
+		if now.Sub(b.updated) >= rl.limit.Period {
+			delete(rl.buckets, key)
+		}
+	}
+}
+
+// bearerToken returns the API token a request was made with, if any.
+func bearerToken(r *http.Request) string {
+	scheme, token, found := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
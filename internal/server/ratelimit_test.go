@@ -0,0 +1,132 @@
+package server
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mjolnir "github.com/dfryer1193/mjolnir/middleware"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    Limit
+		wantErr bool
+	}{
+		{value: "", want: Limit{}},
+		{value: "0", want: Limit{}},
+		{value: "10/s", want: Limit{Requests: 10, Period: time.Second}},
+		{value: "600/m", want: Limit{Requests: 600, Period: time.Minute}},
+		{value: "5/30s", want: Limit{Requests: 5, Period: 30 * time.Second}},
+		{value: "10", wantErr: true},
+		{value: "-1/s", wantErr: true},
+		{value: "10/fortnight", wantErr: true},
+		{value: "10/0s", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseLimit(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := NewRateLimiter("test", Limit{Requests: 2, Period: time.Minute})
+	limiter.now = func() time.Time { return now }
+	handler := mjolnir.ErrorHandler(limiter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})))
+
+	request := func(addr string, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/import", nil)
+		req.RemoteAddr = addr + ":1234"
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for range 2 {
+		if rec := request("192.0.2.1", ""); rec.Code != http.StatusNoContent {
+			t.Fatalf("status = %d within the burst", rec.Code)
+		}
+	}
+	rec := request("192.0.2.1", "")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusTooManyRequests)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "30" {
+		t.Errorf("Retry-After = %q, want 30", got)
+	}
+	if rec := request("192.0.2.2", ""); rec.Code != http.StatusNoContent {
+		t.Errorf("another address was limited: status = %d", rec.Code)
+	}
+
+	// A token is limited wherever it is used from, and does not lift the
+	// limit of the address it is used from.
+	request("192.0.2.3", "script")
+	request("192.0.2.4", "script")
+	if rec := request("192.0.2.5", "script"); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("token over its limit: status = %d", rec.Code)
+	}
+	if rec := request("192.0.2.1", "other"); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("new token on a limited address: status = %d", rec.Code)
+	}
+	if _, exists := limiter.buckets["token:other"]; exists {
+		t.Error("a rejected request should not add a bucket for its token")
+	}
+
+	now = now.Add(30 * time.Second)
+	if rec := request("192.0.2.1", ""); rec.Code != http.StatusNoContent {
+		t.Errorf("status = %d after the bucket refilled", rec.Code)
+	}
+
+	var stats map[string]int
+	if err := json.Unmarshal([]byte(expvar.Get("rate_limit").String()), &stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats["test_allowed"] != 6 || stats["test_rejected"] != 3 {
+		t.Errorf("stats = %v, want 6 allowed and 3 rejected", stats)
+	}
+}
+
+func TestRateLimiter_UnixSocket(t *testing.T) {
+	limiter := NewRateLimiter("unix", Limit{Requests: 1, Period: time.Minute})
+	client := serveUnix(t, RealIP(nil, true)(mjolnir.ErrorHandler(limiter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})))))
+
+	request := func(forwardedFor string) int {
+		req, _ := http.NewRequest(http.MethodGet, "http://go/docs", nil)
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if status := request("203.0.113.1"); status != http.StatusNoContent {
+		t.Fatalf("first request: status = %d", status)
+	}
+	if status := request("203.0.113.1"); status != http.StatusTooManyRequests {
+		t.Errorf("same client over its limit: status = %d, want %d", status, http.StatusTooManyRequests)
+	}
+	if status := request("203.0.113.2"); status != http.StatusNoContent {
+		t.Errorf("another client behind the same proxy was limited: status = %d", status)
+	}
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// unixSocketKey marks the context of connections on a Unix domain socket.
+type unixSocketKey struct{}
+
+// ConnContext marks connections on a Unix domain socket, whose peers have no
+// address, so that RealIP can recognise them. It is meant to be used as the
+// ConnContext of an http.Server.
+func ConnContext(ctx context.Context, c net.Conn) context.Context {
+	if _, ok := c.(*net.UnixConn); ok {
+		return context.WithValue(ctx, unixSocketKey{}, true)
+	}
+	return ctx
+}
+
+// fromUnixSocket reports whether a request arrived on a Unix domain socket.
+func fromUnixSocket(r *http.Request) bool {
+	fromUnix, _ := r.Context().Value(unixSocketKey{}).(bool)
+	return fromUnix
+}
+
+// RealIP returns middleware that replaces the remote address of requests made
+// through a trusted proxy with the address of the client, taken from the
+// X-Forwarded-For or X-Real-IP header. If trustUnixSocket is set, every peer on
+// a Unix domain socket is a trusted proxy. Headers sent by any other peer are
+// ignored, since clients can set them to anything, and their
+// X-Forwarded-Proto header is removed so that handlers building links to the
+// server only ever see one set by a trusted proxy.
+func RealIP(trustedProxies []netip.Prefix, trustUnixSocket bool) func(http.Handler) http.Handler {
+	trusted := func(addr netip.Addr) bool {
+		for _, prefix := range trustedProxies {
+			if prefix.Contains(addr.Unmap()) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !trustUnixSocket || !fromUnixSocket(r) {
+				peer, err := netip.ParseAddr(remoteIP(r))
+				if err != nil || !trusted(peer) {
+					r.Header.Del("X-Forwarded-Proto")
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			// Each proxy appends the address it received the request from, so
+			// the client is the last address not added by a trusted proxy.
+			client := ""
+			forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+			for i := len(forwarded) - 1; i >= 0; i-- {
+				addr, err := netip.ParseAddr(strings.TrimSpace(forwarded[i]))
+				if err != nil {
+					break
+				}
+				client = addr.String()
+				if !trusted(addr) {
+					break
+				}
+			}
+			if client == "" {
+				if addr, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); err == nil {
+					client = addr.String()
+				}
+			}
+
+			if client != "" {
+				r.RemoteAddr = net.JoinHostPort(client, "0")
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// remoteIP returns the IP address of the peer of a request, without its port.
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// ParsePrefixes parses a list of IP addresses and CIDR ranges.
+func ParsePrefixes(values []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(values))
+	for _, value := range values {
+		if prefix, err := netip.ParsePrefix(value); err == nil {
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(value)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
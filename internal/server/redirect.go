@@ -0,0 +1,42 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RedirectToHTTPS returns a handler that sends every request to the same URL
+// over HTTPS on the given port, so that bare http://go/x links keep working
+// once golinks serves TLS.
+func RedirectToHTTPS(httpsPort int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hostname := (&url.URL{Host: r.Host}).Hostname()
+		host := net.JoinHostPort(hostname, strconv.Itoa(httpsPort))
+		if httpsPort == 443 {
+			host = strings.TrimSuffix(host, ":443")
+		}
+
+		target := "https://" + host + r.URL.RequestURI()
+		http.Redirect(w, r, target, http.StatusPermanentRedirect)
+	})
+}
+
+// HSTS returns middleware that tells browsers to only use HTTPS for this host
+// for maxAge. It only applies to requests that arrived over TLS, as browsers
+// ignore the header otherwise.
+func HSTS(maxAge time.Duration) func(http.Handler) http.Handler {
+	value := fmt.Sprintf("max-age=%d", int64(maxAge.Seconds()))
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.TLS != nil {
+				w.Header().Set("Strict-Transport-Security", value)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRedirectToHTTPS(t *testing.T) {
+	tests := []struct {
+		name      string
+		url       string
+		httpsPort int
+		want      string
+	}{
+		{name: "Default port", url: "http://go/docs?q=1", httpsPort: 443, want: "https://go/docs?q=1"},
+		{name: "Custom port", url: "http://go:8080/docs", httpsPort: 8443, want: "https://go:8443/docs"},
+		{name: "IPv6 host", url: "http://[::1]:8080/docs", httpsPort: 8443, want: "https://[::1]:8443/docs"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			RedirectToHTTPS(tt.httpsPort).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.url, nil))
+			if rec.Code != http.StatusPermanentRedirect {
+				t.Errorf("status = %d, want %d", rec.Code, http.StatusPermanentRedirect)
+			}
+			if got := rec.Header().Get("Location"); got != tt.want {
+				t.Errorf("Location = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHSTS(t *testing.T) {
+	handler := HSTS(24 * time.Hour)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "https://go/", nil)
+	req.TLS = &tls.ConnectionState{}
+	handler.ServeHTTP(rec, req)
+	if got := rec.Header().Get("Strict-Transport-Security"); got != "max-age=86400" {
+		t.Errorf("Strict-Transport-Security = %q over TLS, want max-age=86400", got)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://go/", nil))
+	if got := rec.Header().Get("Strict-Transport-Security"); got != "" {
+		t.Errorf("Strict-Transport-Security = %q over plain HTTP, want none", got)
+	}
+}
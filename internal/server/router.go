@@ -0,0 +1,36 @@
+package server
+
+import (
+	"net/netip"
+
+	"github.com/dfryer1193/golinks/internal/tracing"
+	mjolnir "github.com/dfryer1193/mjolnir/middleware"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/rs/zerolog/log"
+)
+
+// RouterOptions configures the middleware of routers made by NewRouter.
+type RouterOptions struct {
+	// TrustedProxies are the peers whose forwarding headers are believed.
+	TrustedProxies []netip.Prefix
+	// TrustUnixSocket believes the forwarding headers of peers on a Unix
+	// domain socket.
+	TrustUnixSocket bool
+	// RedirectSampleRate logs one in this many successful redirects.
+	RedirectSampleRate int
+}
+
+// NewRouter returns a router with the middleware every golinks listener uses:
+// client address resolution, panic recovery, request IDs, tracing, access
+// logging and the error responses of handlers that use mjolnir's SetError.
+func NewRouter(opts RouterOptions) *chi.Mux {
+	r := chi.NewRouter()
+	r.Use(RealIP(opts.TrustedProxies, opts.TrustUnixSocket))
+	r.Use(middleware.Recoverer)
+	r.Use(mjolnir.RequestID)
+	r.Use(tracing.Middleware)
+	r.Use(AccessLog(log.Logger, opts.RedirectSampleRate))
+	r.Use(mjolnir.ErrorHandler)
+	return r
+}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog/log"
+)
+
+// CertReloader serves a TLS certificate and key pair from disk, and reloads it
+// whenever either file changes, so that renewed certificates are picked up
+// without a restart.
+type CertReloader struct {
+	certPath string
+	keyPath  string
+	certLock *sync.RWMutex
+	cert     *tls.Certificate
+	watcher  *fsnotify.Watcher
+}
+
+// NewCertReloader loads the certificate and key pair and starts watching them.
+func NewCertReloader(certPath string, keyPath string) (*CertReloader, error) {
+	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("loading TLS certificate: %w", err)
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("watching TLS certificate: %w", err)
+	}
+
+	reloader := &CertReloader{
+		certPath: certPath,
+		keyPath:  keyPath,
+		certLock: &sync.RWMutex{},
+		cert:     &cert,
+		watcher:  watcher,
+	}
+
+	// The directories are watched rather than the files, since certificates
+	// are usually replaced by renaming new files over the old ones, or by
+	// swapping a symlink as Kubernetes does for mounted secrets.
+	for _, dir := range []string{filepath.Dir(certPath), filepath.Dir(keyPath)} {
+		if err := watcher.Add(dir); err != nil {
+			watcher.Close()
+			return nil, fmt.Errorf("watching TLS certificate: %w", err)
+		}
+	}
+
+	go reloader.watch()
+
+	return reloader, nil
+}
+
+// GetCertificate returns the current certificate, for use in tls.Config.
+func (c *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.certLock.RLock()
+	defer c.certLock.RUnlock()
+	return c.cert, nil
+}
+
+// Close stops watching for changes.
+func (c *CertReloader) Close() error {
+	return c.watcher.Close()
+}
+
+func (c *CertReloader) watch() {
+	for {
+		select {
+		case _, ok := <-c.watcher.Events:
+			if !ok {
+				return
+			}
+			c.reload()
+		case err, ok := <-c.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Err(err).Msg("TLS certificate watch error received")
+		}
+	}
+}
+
+// reload replaces the certificate if the files on disk hold a valid pair. The
+// certificate and key are rarely written at the same instant, so a pair that
+// does not match yet keeps the current certificate until the other file
+// catches up.
+func (c *CertReloader) reload() {
+	cert, err := tls.LoadX509KeyPair(c.certPath, c.keyPath)
+	if err != nil {
+		log.Debug().Err(err).Msg("Not reloading TLS certificate")
+		return
+	}
+
+	c.certLock.Lock()
+	defer c.certLock.Unlock()
+	if slices.EqualFunc(c.cert.Certificate, cert.Certificate, bytes.Equal) {
+		return
+	}
+	c.cert = &cert
+	log.Info().Str("file", c.certPath).Msg("Reloaded TLS certificate")
+}
+
+// ParseTLSVersion parses a TLS version such as "1.2".
+func ParseTLSVersion(version string) (uint16, error) {
+	switch version {
+	case "1.0":
+		return tls.VersionTLS10, nil
+	case "1.1":
+		return tls.VersionTLS11, nil
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("unknown TLS version %q: expected 1.0, 1.1, 1.2 or 1.3", version)
+	}
+}
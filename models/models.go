@@ -1,11 +1,93 @@
 package models
 
+import (
+	"slices"
+	"time"
+)
+
+// LinkState describes whether a link currently resolves, based on its schedule.
+type LinkState string
+
+const (
+	StateActive  LinkState = "active"
+	StatePending LinkState = "pending"
+	StateExpired LinkState = "expired"
+)
+
+// RedirectMode is how a link sends visitors to its target: one of the redirect
+// status codes, or an interstitial page that shows the target first.
+type RedirectMode string
+
+const (
+	RedirectDefault      RedirectMode = ""
+	RedirectMoved        RedirectMode = "301"
+	RedirectFound        RedirectMode = "302"
+	RedirectTemporary    RedirectMode = "307"
+	RedirectPermanent    RedirectMode = "308"
+	RedirectInterstitial RedirectMode = "interstitial"
+)
+
+// IsValid reports whether the mode is one of the known redirect modes.
+func (m RedirectMode) IsValid() bool {
+	switch m {
+	case RedirectDefault, RedirectMoved, RedirectFound, RedirectTemporary, RedirectPermanent, RedirectInterstitial:
+		return true
+	}
+	return false
+}
+
+// IsPermanent reports whether browsers may cache the redirect.
+func (m RedirectMode) IsPermanent() bool {
+	return m == RedirectMoved || m == RedirectPermanent
+}
+
 type Entry struct {
-	Path   string `json:"path"`
-	Target string `json:"target"`
+	Path          string       `json:"path"`
+	Target        string       `json:"target"`
+	AliasOf       string       `json:"aliasOf,omitempty"`
+	Description   string       `json:"description,omitempty"`
+	Owner         string       `json:"owner,omitempty"`
+	Tags          []string     `json:"tags,omitempty"`
+	Redirect      RedirectMode `json:"redirect,omitempty"`
+	ActiveFrom    *time.Time   `json:"activeFrom,omitempty"`
+	ExpiresAt     *time.Time   `json:"expiresAt,omitempty"`
+	DeletedAt     *time.Time   `json:"deletedAt,omitempty"`
+	CreatedAt     *time.Time   `json:"createdAt,omitempty"`
+	UpdatedAt     *time.Time   `json:"updatedAt,omitempty"`
+	State         LinkState    `json:"state,omitempty"`
+	Clicks        int64        `json:"clicks"`
+	LastClickedAt *time.Time   `json:"lastClickedAt,omitempty"`
+}
+
+// ClickStats counts how often a link has been followed. Popularity is a click
+// count that decays over time, as of LastClickedAt.
+type ClickStats struct {
+	Count         int64
+	LastClickedAt time.Time
+	Popularity    float64
 }
 
 type UpdateDelta struct {
 	Old *Entry `json:"old"`
 	New *Entry `json:"new"`
 }
+
+// StateAt reports the schedule state of the entry at the given time. Entries
+// without a schedule are always active.
+func (e *Entry) StateAt(now time.Time) LinkState {
+	if e.ActiveFrom != nil && now.Before(*e.ActiveFrom) {
+		return StatePending
+	}
+	if e.ExpiresAt != nil && !now.Before(*e.ExpiresAt) {
+		return StateExpired
+	}
+	return StateActive
+}
+
+// Clone returns a copy of the entry that can be handed out without exposing
+// the caller to later mutations.
+func (e *Entry) Clone() *Entry {
+	clone := *e
+	clone.Tags = slices.Clone(e.Tags)
+	return &clone
+}